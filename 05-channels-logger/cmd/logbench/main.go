@@ -0,0 +1,95 @@
+// Command logbench compares the ways the logger can write entries: the
+// fmt.Printf call per entry of the original logger() function, a sink that
+// writes every entry on its own, and a sink that batches them. The
+// allocations of the hot path are measured by the benchmarks of the logger
+// package instead.
+//
+//	go run ./05-channels-logger/cmd/logbench -out /tmp/bench.log
+//	go test -run - -bench . -benchmem ./05-channels-logger/logger
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+)
+
+var out = flag.String("out", os.DevNull, "file the benchmarks write to")
+
+func openOut(b *testing.B) *os.File {
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return f
+}
+
+// benchmarkPrintf reproduces logger(): one channel and one Fprintf per entry.
+func benchmarkPrintf(b *testing.B) {
+	f := openOut(b)
+	defer f.Close()
+	type logEntry struct {
+		time     time.Time
+		severity string
+		message  string
+	}
+	logCh := make(chan logEntry, 50)
+	doneCh := make(chan struct{})
+	go func() {
+		for entry := range logCh {
+			fmt.Fprintf(f, "%v - [%v] %v\n", entry.time.Format("2006-01-02T15:04:05"), entry.severity, entry.message)
+		}
+		close(doneCh)
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logCh <- logEntry{time.Now(), "INFO", "App is running"}
+	}
+	close(logCh)
+	<-doneCh
+}
+
+func benchmarkSink(b *testing.B, newSink func(w io.Writer) logger.Sink) {
+	f := openOut(b)
+	defer f.Close()
+	l := logger.New(logger.WithSink("bench", newSink(f), logger.LevelInfo))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("App is running")
+	}
+	l.Close() // Includes the final flush in the measurement
+}
+
+func main() {
+	flag.Parse()
+	benchmarks := []struct {
+		name string
+		fn   func(b *testing.B)
+	}{
+		{"Printf", benchmarkPrintf},
+		{"PerEntry", func(b *testing.B) {
+			benchmarkSink(b, func(w io.Writer) logger.Sink {
+				return logger.NewWriterSink(w, &logger.TextEncoder{})
+			})
+		}},
+		{"Batched", func(b *testing.B) {
+			benchmarkSink(b, func(w io.Writer) logger.Sink {
+				return logger.NewWriterSink(w, &logger.TextEncoder{}).Batched(logger.Batch{
+					MaxEntries: 256,
+					MaxLatency: 10 * time.Millisecond,
+				})
+			})
+		}},
+	}
+	for _, bm := range benchmarks {
+		res := testing.Benchmark(bm.fn)
+		fmt.Printf("%-14s %s\t%s\n", bm.name, res, res.MemString())
+	}
+}
@@ -0,0 +1,171 @@
+// Command logcollector is a tiny collector for logger.ShipperSink. It writes
+// every record it receives to a file (or stdout), once and in order, and
+// remembers the last record of each sender so a reconnecting shipper does not
+// send duplicates.
+//
+//	go run ./05-channels-logger/cmd/logcollector -listen 127.0.0.1:5170 -out collected.log -state collector.json
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	listen    = flag.String("listen", "127.0.0.1:5170", "address to listen on")
+	outPath   = flag.String("out", "", "file to append records to (default stdout)")
+	statePath = flag.String("state", "", "file to keep the last sequence number of each sender in, so restarts do not cause duplicates")
+)
+
+// collector is shared by every connection.
+type collector struct {
+	mtx     sync.Mutex // Serialises writes, so records from different senders do not interleave
+	out     *bufio.Writer
+	outFile *os.File
+	last    map[string]uint64 // Last sequence number written, per sender
+	senders map[string]*sync.Mutex
+}
+
+func main() {
+	flag.Parse()
+	c := &collector{last: make(map[string]uint64), senders: make(map[string]*sync.Mutex)}
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.OpenFile(*outPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.outFile = f
+		out = f
+	}
+	c.out = bufio.NewWriter(out)
+	if *statePath != "" {
+		if b, err := os.ReadFile(*statePath); err == nil {
+			if err := json.Unmarshal(b, &c.last); err != nil {
+				log.Fatalf("reading %s: %v", *statePath, err)
+			}
+		}
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("listening on %s", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			defer conn.Close()
+			if err := c.serve(conn); err != nil && err != io.EOF {
+				log.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// serve handles one shipper connection, following the protocol described in
+// the logger package.
+func (c *collector) serve(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	hello, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	sender, ok := strings.CutPrefix(strings.TrimSpace(hello), "HELLO ")
+	if !ok || sender == "" {
+		return fmt.Errorf("expected HELLO, got %q", hello)
+	}
+
+	// A sender that reconnects while its old connection is still open must
+	// wait for it, or both could write the same records.
+	c.mtx.Lock()
+	senderMtx, ok := c.senders[sender]
+	if !ok {
+		senderMtx = &sync.Mutex{}
+		c.senders[sender] = senderMtx
+	}
+	c.mtx.Unlock()
+	senderMtx.Lock()
+	defer senderMtx.Unlock()
+	c.mtx.Lock()
+	last := c.last[sender]
+	c.mtx.Unlock()
+
+	// Records written before the connection drops must be committed too, or
+	// the shipper sends them again and they are written twice
+	defer func() {
+		if err := c.commit(sender, last); err != nil {
+			log.Printf("%s: %v", sender, err)
+		}
+	}()
+
+	if _, err := fmt.Fprintf(conn, "ACK %d\n", last); err != nil {
+		return err
+	}
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		n, record, ok := bytes.Cut(bytes.TrimRight(line, "\n"), []byte{' '})
+		seq, perr := strconv.ParseUint(string(n), 10, 64)
+		if !ok || perr != nil {
+			return fmt.Errorf("malformed record %q", line)
+		}
+		if seq > last {
+			c.mtx.Lock()
+			c.out.Write(record)
+			c.out.WriteByte('\n')
+			c.mtx.Unlock()
+			last = seq
+		}
+		if r.Buffered() == 0 { // Acknowledge once the shipper is waiting on us
+			if err := c.commit(sender, last); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(conn, "ACK %d\n", last); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// commit makes the records up to last durable before they are acknowledged.
+func (c *collector) commit(sender string, last uint64) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if err := c.out.Flush(); err != nil {
+		return err
+	}
+	if c.outFile != nil {
+		if err := c.outFile.Sync(); err != nil {
+			return err
+		}
+	}
+	c.last[sender] = last
+	if *statePath == "" {
+		return nil
+	}
+	b, err := json.Marshal(c.last)
+	if err != nil {
+		return err
+	}
+	tmp := *statePath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, *statePath)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+)
+
+func newTestCollector() (*collector, *bytes.Buffer) {
+	var out bytes.Buffer
+	c := &collector{
+		out:     bufio.NewWriter(&out),
+		last:    make(map[string]uint64),
+		senders: make(map[string]*sync.Mutex),
+	}
+	return c, &out
+}
+
+// connect runs serve on one end of a pipe, sends HELLO and returns the other
+// end, with the first ACK already read into ack.
+func connect(t *testing.T, c *collector, sender string) (conn net.Conn, r *bufio.Reader, ack string, done chan error) {
+	t.Helper()
+	conn, server := net.Pipe()
+	done = make(chan error, 1)
+	go func() {
+		defer server.Close()
+		done <- c.serve(server)
+	}()
+	if _, err := conn.Write([]byte("HELLO " + sender + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	r = bufio.NewReader(conn)
+	ack, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, r, ack, done
+}
+
+func TestServeCommitsRecordsWhenTheConnectionDrops(t *testing.T) {
+	c, out := newTestCollector()
+	conn, _, ack, done := connect(t, c, "app")
+	if ack != "ACK 0\n" {
+		t.Fatalf("first ACK is %q", ack)
+	}
+	// The connection drops in the middle of the third record, before the
+	// collector acknowledges the first two
+	if _, err := conn.Write([]byte("1 one\n2 two\n3 thr")); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	<-done
+
+	if want := "one\ntwo\n"; out.String() != want {
+		t.Fatalf("collector wrote %q, want %q", out.String(), want)
+	}
+	conn, _, ack, done = connect(t, c, "app")
+	defer func() {
+		conn.Close()
+		<-done
+	}()
+	if ack != "ACK 2\n" {
+		t.Fatalf("ACK after reconnecting is %q, want ACK 2", ack)
+	}
+}
+
+func TestServeSkipsRecordsItAlreadyHas(t *testing.T) {
+	c, out := newTestCollector()
+	c.last["app"] = 1
+	conn, r, ack, done := connect(t, c, "app")
+	if ack != "ACK 1\n" {
+		t.Fatalf("first ACK is %q, want ACK 1", ack)
+	}
+	if _, err := conn.Write([]byte("1 one\n2 two\n")); err != nil {
+		t.Fatal(err)
+	}
+	for ack != "ACK 2\n" {
+		var err error
+		if ack, err = r.ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+	<-done
+	if want := "two\n"; out.String() != want {
+		t.Fatalf("collector wrote %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,196 @@
+// Command logquery searches the files written by the logger package, in the
+// text format of logger() or in JSON or logfmt.
+//
+//	logquery -since 2024-05-01T10:00:00 -until 15m -level warning app.log app-*.log.gz
+//	logquery -grep 'timeout|refused' -field component=db -count app.log
+//	logquery -follow -level error /var/log/app/app.log
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+	"github.com/dangarmol/go-notes/05-channels-logger/logger/logfile"
+)
+
+// fieldFilters collects the repeated -field flags.
+type fieldFilters []fieldFilter
+
+type fieldFilter struct {
+	key   string
+	value string         // For key=value
+	re    *regexp.Regexp // For key~regex
+}
+
+func (f *fieldFilters) String() string {
+	return fmt.Sprint(*f)
+}
+
+func (f *fieldFilters) Set(s string) error {
+	if key, expr, ok := strings.Cut(s, "~"); ok && !strings.Contains(key, "=") {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+		*f = append(*f, fieldFilter{key: key, re: re})
+		return nil
+	}
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("expected key=value or key~regex, got %q", s)
+	}
+	*f = append(*f, fieldFilter{key: key, value: value})
+	return nil
+}
+
+type query struct {
+	since, until time.Time
+	minLevel     logger.Level
+	grep         *regexp.Regexp
+	fields       fieldFilters
+}
+
+func (q *query) match(e *logger.Entry) bool {
+	if e.Level < q.minLevel {
+		return false
+	}
+	if !q.since.IsZero() && e.Time.Before(q.since) {
+		return false
+	}
+	if !q.until.IsZero() && e.Time.After(q.until) {
+		return false
+	}
+	if q.grep != nil && !q.grep.MatchString(e.Message) {
+		return false
+	}
+	for _, ff := range q.fields {
+		value, found := lookup(e, ff.key)
+		if !found {
+			return false
+		}
+		if ff.re != nil && !ff.re.MatchString(value) || ff.re == nil && value != ff.value {
+			return false
+		}
+	}
+	return true
+}
+
+func lookup(e *logger.Entry, key string) (string, bool) {
+	if key == "component" && e.Component != "" {
+		return e.Component, true
+	}
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return fmt.Sprint(f.Value()), true
+		}
+	}
+	return "", false
+}
+
+func main() {
+	var q query
+	since := flag.String("since", "", "only entries at or after this time, or this long ago (e.g. 15m)")
+	until := flag.String("until", "", "only entries at or before this time, or this long ago")
+	level := flag.String("level", "trace", "minimum level")
+	grep := flag.String("grep", "", "regular expression the message must match")
+	flag.Var(&q.fields, "field", "key=value or key~regex the entry must have (repeatable)")
+	follow := flag.Bool("follow", false, "keep reading new lines, across rotations, like tail -F")
+	count := flag.Bool("count", false, "print the number of matching entries per level at the end")
+	quiet := flag.Bool("quiet", false, "do not print the matching lines")
+	flag.Parse()
+
+	var err error
+	if q.since, err = logfile.ParseTimeFlag(*since); err != nil {
+		fail(err)
+	}
+	if q.until, err = logfile.ParseTimeFlag(*until); err != nil {
+		fail(err)
+	}
+	if q.minLevel, err = logger.ParseLevel(*level); err != nil {
+		fail(err)
+	}
+	if *grep != "" {
+		if q.grep, err = regexp.Compile(*grep); err != nil {
+			fail(err)
+		}
+	}
+
+	counts := make(map[logger.Level]int)
+	unparsed := 0
+	handle := func(line []byte) error {
+		e, err := logfile.Parse(line)
+		if err != nil {
+			unparsed++
+			return nil
+		}
+		if !q.match(e) {
+			return nil
+		}
+		counts[e.Level]++
+		if !*quiet {
+			os.Stdout.Write(line)
+			os.Stdout.Write([]byte{'\n'})
+		}
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	paths := flag.Args()
+	switch {
+	case *follow:
+		if len(paths) != 1 {
+			fail(fmt.Errorf("-follow needs exactly one file"))
+		}
+		err = logfile.Follow(ctx, paths[0], false, handle)
+		if err == context.Canceled {
+			err = nil // Interrupted with Ctrl+C, still print the counts
+		}
+	case len(paths) == 0:
+		err = logfile.Scan(os.Stdin, handle)
+	default:
+		for _, p := range paths {
+			if err = scanFile(p, handle); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	if *count {
+		total := 0
+		for l := logger.LevelTrace; l <= logger.LevelFatal; l++ {
+			if counts[l] > 0 {
+				fmt.Printf("%-8s %d\n", l, counts[l])
+				total += counts[l]
+			}
+		}
+		fmt.Printf("%-8s %d\n", "TOTAL", total)
+	}
+	if unparsed > 0 {
+		fmt.Fprintf(os.Stderr, "logquery: skipped %d lines in an unknown format\n", unparsed)
+	}
+}
+
+func scanFile(path string, fn func([]byte) error) error {
+	r, err := logfile.Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return logfile.Scan(r, fn)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "logquery:", err)
+	os.Exit(1)
+}
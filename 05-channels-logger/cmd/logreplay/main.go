@@ -0,0 +1,224 @@
+// Command logreplay reads the files written by the logger package, in the
+// text format of logger() or in JSON or logfmt, and sends their entries again
+// to the sinks given with -sink. By default the entries keep their recorded
+// times and the gaps between them, so collectors and alerting rules see the
+// traffic of an incident as it happened.
+//
+//	logreplay -sink shipper:127.0.0.1:5170 -speed 10 -time replay incident.log
+//	logreplay -sink syslog:udp:127.0.0.1:514 -level warning -speed 0 app-*.log.gz
+//	logreplay -sink store:/tmp/store -time 2024-05-01T10:00:00 -repeat 3 incident.log
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+	"github.com/dangarmol/go-notes/05-channels-logger/logger/logfile"
+	"github.com/dangarmol/go-notes/05-channels-logger/logger/logstore"
+)
+
+// sinkSpecs collects the repeated -sink flags.
+type sinkSpecs []string
+
+func (s *sinkSpecs) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *sinkSpecs) Set(spec string) error {
+	*s = append(*s, spec)
+	return nil
+}
+
+var (
+	sinks  sinkSpecs
+	format = flag.String("format", "text", "encoder of the stdout, file and shipper sinks: text, json or logfmt")
+	spool  = flag.String("spool", filepath.Join(os.TempDir(), "logreplay-spool"), "spool directory of the shipper sink")
+)
+
+// newSink builds a sink from a spec of the -sink flag.
+func newSink(spec string) (logger.Sink, error) {
+	var enc logger.Encoder
+	switch *format {
+	case "text":
+		enc = &logger.TextEncoder{}
+	case "json":
+		enc = &logger.JSONEncoder{}
+	case "logfmt":
+		enc = &logger.LogfmtEncoder{}
+	default:
+		return nil, fmt.Errorf("unknown format %q", *format)
+	}
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "stdout":
+		return logger.NewWriterSink(os.Stdout, enc), nil
+	case "file":
+		return logger.NewFileSink(arg, enc)
+	case "shipper":
+		return logger.NewShipperSink(logger.ShipperConfig{Addr: arg, SpoolDir: *spool, Encoder: enc})
+	case "syslog":
+		network, addr, _ := strings.Cut(arg, ":")
+		return logger.NewSyslogSink(network, addr, logger.NewSyslogEncoder(logger.FacilityLocal0))
+	case "store":
+		return logstore.NewSink(logstore.Config{Dir: arg})
+	case "trace":
+		return logger.NewTraceFileSink(arg)
+	}
+	return nil, fmt.Errorf("unknown sink %q, expected stdout, file:PATH, shipper:ADDR, syslog:NETWORK:ADDR, store:DIR or trace:PATH", spec)
+}
+
+// replayer sends the entries of the files to a logger on the schedule they
+// were recorded with.
+type replayer struct {
+	log      *logger.Logger
+	minLevel logger.Level
+	speed    float64   // 0 sends the entries as fast as the sinks take them
+	now      bool      // Entries get the time they are sent at
+	rebase   time.Time // When set, the new time of the first entry
+	start    time.Time
+	first    time.Time     // Recorded time of the first entry
+	offset   time.Duration // Added to the recorded times for rebase
+	sent     int
+	unparsed int
+}
+
+// restart starts the schedule over, for the next repetition.
+func (r *replayer) restart() {
+	r.start, r.first = time.Now(), time.Time{}
+}
+
+func (r *replayer) replay(ctx context.Context, line []byte) error {
+	e, err := logfile.Parse(line)
+	if err != nil {
+		r.unparsed++
+		return nil
+	}
+	if r.first.IsZero() {
+		r.first = e.Time
+		if !r.rebase.IsZero() {
+			r.offset = r.rebase.Sub(e.Time)
+		}
+	}
+	if e.Level < r.minLevel {
+		return nil // Skipped without waiting, but it still sets the schedule
+	}
+	if r.speed > 0 {
+		due := r.start.Add(time.Duration(float64(e.Time.Sub(r.first)) / r.speed))
+		if wait := time.Until(due); wait > 0 { // Entries out of order are sent at once
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+	if r.now {
+		e.Time = time.Now()
+	} else {
+		e.Time = e.Time.Add(r.offset)
+	}
+	r.log.LogEntry(e)
+	r.sent++
+	return ctx.Err()
+}
+
+func main() {
+	flag.Var(&sinks, "sink", "where to send the entries (repeatable): stdout, file:PATH, shipper:ADDR, syslog:NETWORK:ADDR, store:DIR or trace:PATH")
+	speed := flag.Float64("speed", 1, "multiplier of the recorded pace, 0 sends the entries as fast as possible")
+	retime := flag.String("time", "original", "times of the entries: original, replay for the time they are sent, or the new time of the first entry, absolute or this long ago (e.g. 1h)")
+	level := flag.String("level", "trace", "minimum level of the entries to send")
+	repeat := flag.Int("repeat", 1, "number of times to replay the files, 0 repeats until interrupted")
+	flag.Parse()
+
+	minLevel, err := logger.ParseLevel(*level)
+	if err != nil {
+		fail(err)
+	}
+	if *speed < 0 {
+		fail(errors.New("-speed cannot be negative"))
+	}
+	r := &replayer{minLevel: minLevel, speed: *speed}
+	switch *retime {
+	case "original":
+	case "replay":
+		r.now = true
+	case "":
+		fail(errors.New("-time cannot be empty"))
+	default:
+		if r.rebase, err = logfile.ParseTimeFlag(*retime); err != nil {
+			fail(err)
+		}
+	}
+	paths := flag.Args()
+	if len(paths) == 0 && *repeat != 1 {
+		fail(errors.New("-repeat needs files, standard input can only be read once"))
+	}
+	if len(sinks) == 0 {
+		sinks = sinkSpecs{"stdout"}
+	}
+
+	opts := []logger.Option{
+		logger.WithLevel(logger.LevelTrace),
+		logger.WithBackpressure(logger.Block()), // A replay must not lose entries to its own speed
+	}
+	for _, spec := range sinks {
+		s, err := newSink(spec)
+		if err != nil {
+			fail(err)
+		}
+		opts = append(opts, logger.WithSink(spec, s, logger.LevelTrace))
+	}
+	r.log = logger.New(opts...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	handle := func(line []byte) error {
+		return r.replay(ctx, line)
+	}
+	for i := 0; *repeat == 0 || i < *repeat; i++ {
+		r.restart()
+		if len(paths) == 0 {
+			err = logfile.Scan(os.Stdin, handle)
+		}
+		for _, p := range paths {
+			if err = replayFile(p, handle); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if cerr := r.log.Close(); err == nil || err == context.Canceled {
+		err = cerr // Interrupted with Ctrl+C, what was sent is still flushed
+	}
+	fmt.Fprintf(os.Stderr, "logreplay: sent %d entries\n", r.sent)
+	if r.unparsed > 0 {
+		fmt.Fprintf(os.Stderr, "logreplay: skipped %d lines in an unknown format\n", r.unparsed)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func replayFile(path string, fn func([]byte) error) error {
+	f, err := logfile.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return logfile.Scan(f, fn)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "logreplay:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,113 @@
+// Command logstore reads the stores written by logstore.Sink. Thanks to
+// their index it seeks straight to the time range asked for, instead of
+// reading every file like logquery does.
+//
+//	logstore -since 2024-05-01T10:00:00 -until 2024-05-01T10:05:00 /var/log/app/store
+//	logstore -since 15m -level error -json /var/log/app/store
+//	logstore -segments /var/log/app/store
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+	"github.com/dangarmol/go-notes/05-channels-logger/logger/logfile"
+	"github.com/dangarmol/go-notes/05-channels-logger/logger/logstore"
+)
+
+func main() {
+	since := flag.String("since", "", "only entries at or after this time, or this long ago (e.g. 15m)")
+	until := flag.String("until", "", "only entries at or before this time, or this long ago")
+	level := flag.String("level", "trace", "minimum level")
+	asJSON := flag.Bool("json", false, "print the entries as JSON instead of text")
+	count := flag.Bool("count", false, "print the number of matching entries per level at the end")
+	quiet := flag.Bool("quiet", false, "do not print the matching entries")
+	segments := flag.Bool("segments", false, "describe the segments of the store instead")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fail(fmt.Errorf("expected the directory of a store"))
+	}
+	dir := flag.Arg(0)
+
+	if *segments {
+		if err := printSegments(dir); err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	var q logstore.Query
+	var err error
+	if q.Since, err = logfile.ParseTimeFlag(*since); err != nil {
+		fail(err)
+	}
+	if q.Until, err = logfile.ParseTimeFlag(*until); err != nil {
+		fail(err)
+	}
+	if q.MinLevel, err = logger.ParseLevel(*level); err != nil {
+		fail(err)
+	}
+	var enc logger.Encoder = &logger.TextEncoder{Time: logger.TimeRFC3339Nano}
+	if *asJSON {
+		enc = &logger.JSONEncoder{}
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	counts := make(map[logger.Level]int)
+	var buf []byte
+	err = logstore.Scan(dir, q, func(e *logger.Entry) error {
+		counts[e.Level]++
+		if *quiet {
+			return nil
+		}
+		buf = enc.Encode(buf[:0], e)
+		_, err := out.Write(buf)
+		return err
+	})
+	if err != nil {
+		out.Flush()
+		fail(err)
+	}
+
+	if *count {
+		total := 0
+		for l := logger.LevelTrace; l <= logger.LevelFatal; l++ {
+			if counts[l] > 0 {
+				fmt.Fprintf(out, "%-8s %d\n", l, counts[l])
+				total += counts[l]
+			}
+		}
+		fmt.Fprintf(out, "%-8s %d\n", "TOTAL", total)
+	}
+}
+
+func printSegments(dir string) error {
+	infos, err := logstore.Segments(dir)
+	if err != nil {
+		return err
+	}
+	for _, s := range infos {
+		levels := make([]string, len(s.Levels))
+		for i, l := range s.Levels {
+			levels[i] = l.String()
+		}
+		state := "indexed"
+		if !s.Indexed {
+			state = "open"
+		}
+		fmt.Printf("%s\t%-7s %10d bytes %8d entries %5d blocks  %s .. %s  %s\n", s.Path, state, s.Size, s.Entries, s.Blocks,
+			s.First.Format(time.RFC3339), s.Last.Format(time.RFC3339), strings.Join(levels, ","))
+	}
+	return nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "logstore:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,172 @@
+package logger
+
+import (
+	"math"
+	"time"
+)
+
+type backpressureKind int
+
+const (
+	bpBlock backpressureKind = iota
+	bpBlockTimeout
+	bpDropNewest
+	bpDropOldest
+	bpSample
+)
+
+// Backpressure decides what happens to an entry when the channel buffer is
+// full. The zero value blocks, like sending on the channel directly.
+type Backpressure struct {
+	kind    backpressureKind
+	timeout time.Duration
+	every   int64
+}
+
+// Block waits for as long as it takes for the consumer to make room.
+func Block() Backpressure {
+	return Backpressure{kind: bpBlock}
+}
+
+// BlockTimeout waits up to d for room in the channel, then drops the entry.
+func BlockTimeout(d time.Duration) Backpressure {
+	return Backpressure{kind: bpBlockTimeout, timeout: d}
+}
+
+// DropNewest drops the entry that is being sent, keeping the buffered ones.
+func DropNewest() Backpressure {
+	return Backpressure{kind: bpDropNewest}
+}
+
+// DropOldest drops the oldest buffered entry to make room for the new one, so
+// the buffer behaves like a ring holding the most recent entries.
+func DropOldest() Backpressure {
+	return Backpressure{kind: bpDropOldest}
+}
+
+// Sample keeps one of every n entries sent while the channel is full, blocking
+// until there is room for it, and drops the rest.
+func Sample(n int) Backpressure {
+	if n < 1 {
+		n = 1
+	}
+	return Backpressure{kind: bpSample, every: int64(n)}
+}
+
+// WithBackpressure sets what happens when the channel buffer is full.
+// Defaults to Block.
+func WithBackpressure(b Backpressure) Option {
+	return func(c *config) {
+		c.backpressure = b
+	}
+}
+
+// WithNoDrop makes entries at min or above block instead of being dropped by
+// the backpressure policy, e.g. WithNoDrop(LevelError). With DropOldest, the
+// entries below min are dropped as they are sent while protected ones are
+// buffered, so the protected ones keep their place in the order.
+func WithNoDrop(min Level) Option {
+	return func(c *config) {
+		c.noDrop = min
+	}
+}
+
+// levelNone is above every level, so nothing is protected from dropping.
+const levelNone Level = math.MaxInt8
+
+// enqueue puts e in the channel according to the backpressure policy and
+// reports whether it made it.
+func (c *core) enqueue(e *Entry) bool {
+	if c.countsProtected() && e.Level >= c.noDrop {
+		c.popMtx.Lock()
+		c.protected.Add(1)
+		c.popMtx.Unlock()
+		if !c.block(e) {
+			c.protected.Add(-1)
+			return false
+		}
+		return true
+	}
+	select {
+	case c.ch <- e:
+		return true
+	default:
+	}
+	if e.Level >= c.noDrop {
+		return c.block(e)
+	}
+	switch c.backpressure.kind {
+	case bpBlockTimeout:
+		timer := time.NewTimer(c.backpressure.timeout)
+		defer timer.Stop()
+		select {
+		case c.ch <- e:
+			return true
+		case <-timer.C:
+			return false
+		case <-c.closing:
+			return false
+		}
+	case bpDropNewest:
+		return false
+	case bpDropOldest:
+		for {
+			select {
+			case c.ch <- e:
+				return true
+			default:
+			}
+			if !c.dropOldest() {
+				return false
+			}
+		}
+	case bpSample:
+		if c.sampled.Add(1)%c.backpressure.every == 0 {
+			return c.block(e)
+		}
+		return false
+	}
+	return c.block(e)
+}
+
+// countsProtected tells whether enqueue keeps count of the entries that
+// WithNoDrop protects, which DropOldest needs so it never takes one of them
+// off the head of the channel.
+func (c *core) countsProtected() bool {
+	return c.backpressure.kind == bpDropOldest && c.noDrop != levelNone
+}
+
+// dropOldest drops the entry at the head of the channel, and reports whether
+// the entry being sent may try again. The head may be protected when entries
+// protected by WithNoDrop are in the channel, and putting it back would move
+// it behind newer entries, so the entry being sent is dropped instead.
+func (c *core) dropOldest() bool {
+	c.popMtx.Lock()
+	defer c.popMtx.Unlock()
+	if c.protected.Load() > 0 {
+		return false
+	}
+	select {
+	case old := <-c.ch:
+		c.accepted.Add(-1)
+		c.recordDrop(old)
+	case <-c.closing:
+		return false
+	default: // The consumer made room meanwhile
+	}
+	return true
+}
+
+// block waits until there is room in the channel or Shutdown starts.
+func (c *core) block(e *Entry) bool {
+	select {
+	case c.ch <- e:
+		return true
+	case <-c.closing:
+		return false
+	}
+}
+
+func (c *core) recordDrop(e *Entry) {
+	c.dropped[levelIndex(e.Level)].Add(1)
+}
@@ -0,0 +1,160 @@
+package logger
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stalledLogger returns a logger whose sink is blocked with a full queue and
+// whose consumer goroutine waits for it, so the channel holds every entry
+// sent next. Its channel and the queue of its sink hold two entries each.
+func stalledLogger(t *testing.T, opts ...Option) (*Logger, *gatedSink) {
+	t.Helper()
+	sink := newGatedSink(0)
+	opts = append([]Option{WithSink("gated", sink, LevelTrace), WithBufferSize(2), WithSinkTimeout(time.Hour)}, opts...)
+	l := New(opts...)
+	// One entry being written, two in the queue of the sink, and one the
+	// consumer is trying to add to it. Each is sent once the channel is
+	// empty, so the policy never drops them.
+	waitFor := func(done func(s Stats) bool) {
+		deadline := time.Now().Add(time.Second)
+		for s := l.Stats(); !done(s); s = l.Stats() {
+			if time.Now().After(deadline) {
+				t.Fatalf("logger did not stall: %+v", s)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		l.Info(fmt.Sprint("stall ", i))
+		waitFor(func(s Stats) bool { return s.Queued == 0 })
+	}
+	waitFor(func(s Stats) bool { return s.Sinks[0].Queued == 2 })
+	return l, sink
+}
+
+// release unblocks the sink, waits for the sends still blocked, closes l and
+// returns the messages written after the ones stalledLogger sent.
+func release(l *Logger, sink *gatedSink, blocked ...<-chan struct{}) []string {
+	close(sink.gate)
+	for _, done := range blocked {
+		<-done
+	}
+	l.Close()
+	var msgs []string
+	for _, msg := range sink.written() {
+		if !strings.HasPrefix(msg, "stall ") {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// returnsWithin tells whether f returns within d. If it does not, f keeps
+// running in its goroutine.
+func returnsWithin(d time.Duration, f func()) (returned bool, done <-chan struct{}) {
+	ch := make(chan struct{})
+	go func() {
+		f()
+		close(ch)
+	}()
+	select {
+	case <-ch:
+		return true, ch
+	case <-time.After(d):
+		return false, ch
+	}
+}
+
+func TestBackpressurePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Backpressure
+		blocks  bool     // Whether sending to the full channel blocks
+		written []string // Entries written after the buffered b0 and b1
+	}{
+		{"block", Block(), true, []string{"b0", "b1", "new"}},
+		{"block timeout", BlockTimeout(10 * time.Millisecond), false, []string{"b0", "b1"}},
+		{"drop newest", DropNewest(), false, []string{"b0", "b1"}},
+		{"drop oldest", DropOldest(), false, []string{"b1", "new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, sink := stalledLogger(t, WithBackpressure(tt.policy))
+			l.Info("b0")
+			l.Info("b1")
+			returned, done := returnsWithin(50*time.Millisecond, func() { l.Info("new") })
+			if returned == tt.blocks {
+				t.Fatalf("sending to a full channel returned: %v, want %v", returned, !tt.blocks)
+			}
+			dropped := l.Stats().Dropped
+			msgs := release(l, sink, done)
+			if !slices.Equal(msgs, tt.written) {
+				t.Errorf("sink wrote %q, want %q", msgs, tt.written)
+			}
+			if want := int64(3 - len(tt.written)); dropped != want {
+				t.Errorf("Stats reported %d dropped entries, want %d", dropped, want)
+			}
+		})
+	}
+}
+
+func TestSampleKeepsOneEntryInN(t *testing.T) {
+	l, sink := stalledLogger(t, WithBackpressure(Sample(3)))
+	l.Info("b0")
+	l.Info("b1")
+	l.Info("n0")
+	l.Info("n1")
+	returned, done := returnsWithin(50*time.Millisecond, func() { l.Info("n2") })
+	if returned {
+		t.Fatal("the sampled entry did not wait for room in the channel")
+	}
+	msgs := release(l, sink, done)
+	if want := []string{"b0", "b1", "n2"}; !slices.Equal(msgs, want) {
+		t.Errorf("sink wrote %q, want %q", msgs, want)
+	}
+}
+
+func TestNoDropBlocksForProtectedLevels(t *testing.T) {
+	l, sink := stalledLogger(t, WithBackpressure(DropNewest()), WithNoDrop(LevelError))
+	l.Info("b0")
+	l.Info("b1")
+	l.Info("info")
+	returned, done := returnsWithin(50*time.Millisecond, func() { l.Error("error") })
+	if returned {
+		t.Fatal("the protected entry did not wait for room in the channel")
+	}
+	msgs := release(l, sink, done)
+	if want := []string{"b0", "b1", "error"}; !slices.Equal(msgs, want) {
+		t.Errorf("sink wrote %q, want %q", msgs, want)
+	}
+}
+
+func TestDropOldestKeepsProtectedEntriesInOrder(t *testing.T) {
+	t.Run("unprotected head", func(t *testing.T) {
+		l, sink := stalledLogger(t, WithBackpressure(DropOldest()), WithNoDrop(LevelError))
+		l.Info("b0")
+		l.Info("b1")
+		l.Info("new") // Nothing protected is buffered, so b0 makes room
+		if want := []string{"b1", "new"}; !slices.Equal(release(l, sink), want) {
+			t.Errorf("sink did not write %q", want)
+		}
+	})
+	t.Run("protected entries buffered", func(t *testing.T) {
+		l, sink := stalledLogger(t, WithBackpressure(DropOldest()), WithNoDrop(LevelError))
+		l.Error("e0")
+		l.Info("b1")
+		l.Info("new") // Dropped itself, as e0 at the head is protected
+		returned, done := returnsWithin(50*time.Millisecond, func() { l.Error("e1") })
+		if returned {
+			t.Fatal("the protected entry did not wait for room in the channel")
+		}
+		msgs := release(l, sink, done)
+		if want := []string{"e0", "b1", "e1"}; !slices.Equal(msgs, want) {
+			t.Errorf("sink wrote %q, want %q", msgs, want)
+		}
+	})
+}
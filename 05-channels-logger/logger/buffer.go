@@ -0,0 +1,30 @@
+package logger
+
+import "sync"
+
+// maxPooledBuffer is the capacity above which a buffer is not returned to
+// the pool, so one huge entry does not keep its memory alive forever.
+const maxPooledBuffer = 64 << 10
+
+// bufferPool holds the buffers that sinks encode single entries into.
+var bufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+// getBuffer returns an empty buffer from the pool. Pass the same pointer to
+// putBuffer, with the grown slice stored in it, when done.
+func getBuffer() *[]byte {
+	b := bufferPool.Get().(*[]byte)
+	*b = (*b)[:0]
+	return b
+}
+
+func putBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(b)
+}
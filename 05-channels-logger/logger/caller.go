@@ -0,0 +1,143 @@
+package logger
+
+import (
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Caller is the place an entry was logged from.
+type Caller struct {
+	File     string
+	Line     int
+	Function string // Package path and name, like "main.(*server).handle"
+}
+
+// String returns the file and line, with only the last directory of the
+// file, like "logger/caller.go:42". It is empty for an unknown caller.
+func (c Caller) String() string {
+	if c.File == "" {
+		return ""
+	}
+	file := c.File
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			file = file[j+1:]
+		}
+	}
+	return file + ":" + strconv.Itoa(c.Line)
+}
+
+// WithCaller adds the file, line and function of the code calling the
+// logger to every entry. It costs a runtime.Callers call per entry.
+func WithCaller() Option {
+	return func(c *config) {
+		c.caller = true
+	}
+}
+
+// WithStackLevel sets the level from which entries carry the stack of the
+// goroutine that logged them. Defaults to LevelError. Use a level above
+// LevelFatal to never capture stacks.
+func WithStackLevel(min Level) Option {
+	return func(c *config) {
+		c.stackLevel = min
+	}
+}
+
+// AddCallerSkip returns a child logger that reports its callers n frames
+// further up the stack. Wrappers around a Logger use it so entries point at
+// the code calling the wrapper rather than at the wrapper itself.
+func (l *Logger) AddCallerSkip(n int) *Logger {
+	child := *l
+	child.skip += n
+	return &child
+}
+
+// callerSkip is the number of frames from runtime.Callers in addCaller to the
+// code that called a Logger method: runtime.Callers, addCaller, send, log and
+// the method itself.
+const callerSkip = 5
+
+// maxStack is the number of frames kept in a stack.
+const maxStack = 64
+
+// addCaller sets the caller and stack of an entry, when they are wanted. pc
+// is the caller when it is already known, as it is for slog records.
+func (l *Logger) addCaller(e *Entry, pc uintptr) {
+	c := l.core
+	wantStack := e.Level >= c.stackLevel
+	if !c.caller && !wantStack {
+		return
+	}
+	var pcs [maxStack]uintptr
+	size := 1
+	if wantStack {
+		size = maxStack
+	}
+	var n int
+	if pc != 0 {
+		pcs[0], n = pc, 1
+		if wantStack {
+			// Skip the frames of slog, which are between here and pc
+			n = runtime.Callers(2, pcs[:])
+			i := 0
+			for i < n && pcs[i] != pc {
+				i++
+			}
+			if i == n {
+				i = 0 // pc is not on this goroutine's stack, keep everything
+			}
+			n = copy(pcs[:], pcs[i:n])
+		}
+	} else {
+		n = runtime.Callers(callerSkip+l.skip, pcs[:size])
+	}
+	if n == 0 {
+		return
+	}
+
+	frames := runtime.CallersFrames(pcs[:n])
+	var sb strings.Builder
+	for first := true; ; first = false {
+		frame, more := frames.Next()
+		if first && c.caller {
+			e.Caller = Caller{File: frame.File, Line: frame.Line, Function: frame.Function}
+		}
+		if !wantStack {
+			break
+		}
+		sb.WriteString(frame.Function)
+		sb.WriteString("\n\t")
+		sb.WriteString(frame.File)
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+		sb.WriteByte('\n')
+	}
+	e.Stack = sb.String()
+}
+
+// allFields returns the fields of an entry followed by its caller, stack and
+// span, for the encoders.
+func (e *Entry) allFields() []Field {
+	if e.Caller.File == "" && e.Stack == "" && e.Span.Phase == SpanNone {
+		return e.Fields
+	}
+	fields := make([]Field, len(e.Fields), len(e.Fields)+6)
+	copy(fields, e.Fields)
+	if e.Caller.File != "" {
+		fields = append(fields, String("caller", e.Caller.String()), String("func", path.Base(e.Caller.Function)))
+	}
+	if e.Stack != "" {
+		fields = append(fields, String("stack", e.Stack))
+	}
+	if e.Span.Phase != SpanNone {
+		fields = append(fields, String("span", e.Span.Phase.String()), Uint64("span_id", e.Span.ID),
+			Uint64("goroutine", e.Span.Goroutine))
+	}
+	return fields
+}
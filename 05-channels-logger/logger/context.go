@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"context"
+	"time"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	traceIDKey
+	spanIDKey
+	userIDKey
+)
+
+// ContextWithRequestID returns a copy of ctx carrying a request ID, which
+// the *Context logging methods add to every entry as "request_id".
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// ContextWithTrace returns a copy of ctx carrying trace and span IDs, added
+// to entries as "trace_id" and "span_id". Empty IDs are left out.
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	if traceID != "" {
+		ctx = context.WithValue(ctx, traceIDKey, traceID)
+	}
+	if spanID != "" {
+		ctx = context.WithValue(ctx, spanIDKey, spanID)
+	}
+	return ctx
+}
+
+// ContextWithUserID returns a copy of ctx carrying a user ID, added to
+// entries as "user_id".
+func ContextWithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+// RequestIDFromContext returns the request ID stored by ContextWithRequestID.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey).(string)
+	return id, ok
+}
+
+// contextKeyField is a context key whose value is copied into a field.
+type contextKeyField struct {
+	key  any
+	name string
+}
+
+var defaultContextKeys = []contextKeyField{
+	{requestIDKey, "request_id"},
+	{traceIDKey, "trace_id"},
+	{spanIDKey, "span_id"},
+	{userIDKey, "user_id"},
+}
+
+// WithContextKey registers another context key whose value is copied into a
+// field called name by the *Context logging methods, e.g. a tenant ID stored
+// in the context by some middleware.
+func WithContextKey(key any, name string) Option {
+	return func(c *config) {
+		c.contextKeys = append(c.contextKeys, contextKeyField{key, name})
+	}
+}
+
+// appendContextFields appends the registered values found in ctx to fields.
+func (c *core) appendContextFields(fields []Field, ctx context.Context) []Field {
+	if ctx == nil {
+		return fields
+	}
+	for _, k := range c.contextKeys {
+		switch v := ctx.Value(k.key).(type) {
+		case nil:
+		case string:
+			fields = append(fields, String(k.name, v))
+		default:
+			fields = append(fields, Any(k.name, v))
+		}
+	}
+	return fields
+}
+
+// WithContext returns a child logger that adds the registered values in ctx
+// to every entry, for goroutines that log without passing ctx around.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	return l.With(l.core.appendContextFields(nil, ctx)...)
+}
+
+// LogContext is Log with the registered values in ctx added before fields.
+func (l *Logger) LogContext(ctx context.Context, level Level, msg string, fields ...Field) {
+	l.logContext(ctx, level, msg, fields)
+}
+
+// logContext is the LogContext counterpart of log.
+func (l *Logger) logContext(ctx context.Context, level Level, msg string, fields []Field) {
+	if !l.core.levels.enabled(level, l.component) {
+		return
+	}
+	all := l.core.appendContextFields(make([]Field, 0, len(l.core.contextKeys)+len(fields)), ctx)
+	l.send(&Entry{Time: time.Now(), Level: level, Message: msg}, append(all, fields...), 0)
+}
+
+func (l *Logger) TraceContext(ctx context.Context, msg string, fields ...Field) {
+	l.logContext(ctx, LevelTrace, msg, fields)
+}
+
+func (l *Logger) DebugContext(ctx context.Context, msg string, fields ...Field) {
+	l.logContext(ctx, LevelDebug, msg, fields)
+}
+
+func (l *Logger) InfoContext(ctx context.Context, msg string, fields ...Field) {
+	l.logContext(ctx, LevelInfo, msg, fields)
+}
+
+func (l *Logger) WarningContext(ctx context.Context, msg string, fields ...Field) {
+	l.logContext(ctx, LevelWarning, msg, fields)
+}
+
+func (l *Logger) ErrorContext(ctx context.Context, msg string, fields ...Field) {
+	l.logContext(ctx, LevelError, msg, fields)
+}
@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// sseKeepAlive is how often an idle event stream gets a comment line, so
+// proxies do not close it.
+const sseKeepAlive = 15 * time.Second
+
+// ServeHTTP writes the entries in the ring, oldest first, so the recent logs
+// of a running process can be read without access to its log files:
+//
+//	ring := logger.NewRingSink(1000)
+//	log := logger.New(logger.WithSink("debug", ring, logger.LevelDebug), ...)
+//	http.Handle("/debug/logs", ring)
+//
+// The query parameters are:
+//
+//	level=warning      Only entries at this level or above
+//	since=5m           Only entries from the last 5 minutes, or since an RFC 3339 time
+//	format=json        One JSON object per entry instead of text lines
+//	stream=1           Keep the response open and send new entries as Server-Sent Events
+//
+// Streaming is also used when the client asks for text/event-stream, as
+// EventSource does. Each event carries the entry's time as its ID, so a
+// client that reconnects only gets the entries it missed. The endpoint
+// exposes every entry in the ring, so it should not be reachable from
+// outside.
+func (s *RingSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	min := LevelTrace
+	if v := q.Get("level"); v != "" {
+		var err error
+		if min, err = ParseLevel(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var since time.Time
+	if v := q.Get("since"); v != "" {
+		var err error
+		if since, err = parseSince(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var enc Encoder = &TextEncoder{}
+	contentType := "text/plain; charset=utf-8"
+	switch q.Get("format") {
+	case "", "text":
+	case "json":
+		enc = &JSONEncoder{}
+		contentType = "application/x-ndjson"
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q", q.Get("format")), http.StatusBadRequest)
+		return
+	}
+	stream := q.Get("stream") == "1" || q.Get("stream") == "true" ||
+		r.Header.Get("Accept") == "text/event-stream"
+
+	// After a reconnect, EventSource sends the ID of the last event it got
+	after := false
+	if id := r.Header.Get("Last-Event-ID"); stream && id != "" {
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+			since, after = time.Unix(0, n), true
+		}
+	}
+	match := func(e *Entry) bool {
+		if e.Level < min {
+			return false
+		}
+		return since.IsZero() || e.Time.After(since) || !after && e.Time.Equal(since)
+	}
+
+	if !stream {
+		w.Header().Set("Content-Type", contentType)
+		var buf []byte
+		for _, e := range s.Entries() {
+			if match(e) {
+				buf = enc.Encode(buf, e)
+			}
+		}
+		w.Write(buf)
+		return
+	}
+
+	entries, ch, cancel := s.Subscribe(256)
+	defer cancel()
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	var buf []byte
+	send := func(e *Entry) error {
+		if !match(e) {
+			return nil
+		}
+		buf = append(buf[:0], "id: "...)
+		buf = strconv.AppendInt(buf, e.Time.UnixNano(), 10)
+		buf = append(buf, "\ndata: "...)
+		buf = enc.Encode(buf, e) // Encoders escape newlines, so this is a single line
+		buf = append(buf, '\n')
+		_, err := w.Write(buf)
+		return err
+	}
+	for _, e := range entries {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := w.Write([]byte(": keep-alive\n\n")); err != nil {
+				return
+			}
+		case e, ok := <-ch:
+			if !ok {
+				return // The logger was closed
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// parseSince accepts a duration, meaning that long ago, or an RFC 3339 time.
+func parseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since must be a duration or an RFC 3339 time, got %q", s)
+	}
+	return t, nil
+}
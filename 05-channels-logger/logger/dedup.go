@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Dedup configures the stage that collapses identical entries before they
+// reach the sinks. Only entries with the same level are ever collapsed.
+type Dedup struct {
+	// Window is how long entries identical to a previous one are suppressed.
+	// When zero, only consecutive duplicates are collapsed, and their summary
+	// is written every 30 seconds while they keep coming.
+	Window time.Duration
+	// Key decides which entries are identical. Defaults to DedupByMessage.
+	Key func(e *Entry) string
+}
+
+// DedupByMessage treats entries with the same component and message as
+// identical, whatever their fields.
+func DedupByMessage(e *Entry) string {
+	return e.Component + "\x00" + e.Message
+}
+
+// DedupByMessageAndFields treats entries as identical only if their fields
+// are identical too.
+func DedupByMessageAndFields(e *Entry) string {
+	var b strings.Builder
+	b.WriteString(DedupByMessage(e))
+	for _, f := range e.Fields {
+		b.WriteByte(0)
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		b.WriteString(f.text())
+	}
+	return b.String()
+}
+
+// WithDedup collapses identical entries. Instead of every duplicate, the
+// sinks get a single "last message repeated N times" entry.
+func WithDedup(d Dedup) Option {
+	return func(c *config) {
+		if d.Key == nil {
+			d.Key = DedupByMessage
+		}
+		c.dedup = &d
+	}
+}
+
+// dedupIdle is how long consecutive duplicates are held before their summary
+// is written, when no different entry comes along.
+const dedupIdle = time.Second
+
+// dedupRepeat is how often a summary of consecutive duplicates is written
+// while they keep coming, like the "repeated N times" lines of syslog.
+const dedupRepeat = 30 * time.Second
+
+// dedupState tracks the duplicates of one entry.
+type dedupState struct {
+	first    *Entry
+	repeated int
+	last     time.Time // Time of the last duplicate
+	since    time.Time // Start of the duplicates counted in repeated, when Window == 0
+	expires  time.Time
+}
+
+// deduper runs on the consumer goroutine, so it needs no locking.
+type deduper struct {
+	Dedup
+	states     map[string]*dedupState // Used when Window > 0
+	prevKey    string                 // Used when Window == 0
+	prev       *dedupState
+	suppressed atomic.Int64
+}
+
+func newDeduper(d *Dedup) *deduper {
+	return &deduper{Dedup: *d, states: make(map[string]*dedupState)}
+}
+
+// interval is how often flush should be called.
+func (d *deduper) interval() time.Duration {
+	if d.Window > 0 {
+		return max(d.Window/2, time.Millisecond)
+	}
+	return dedupIdle
+}
+
+// filter passes e on to next unless it duplicates a previous entry.
+func (d *deduper) filter(e *Entry, next func(*Entry)) {
+	key := e.Level.String() + "\x00" + d.Key(e)
+	if d.Window <= 0 {
+		if d.prev != nil && key == d.prevKey {
+			d.prev.repeated++
+			d.prev.last = e.Time
+			d.suppressed.Add(1)
+			return
+		}
+		d.emit(d.prev, next)
+		d.prevKey, d.prev = key, &dedupState{first: e, since: e.Time}
+		next(e)
+		return
+	}
+	if st, ok := d.states[key]; ok {
+		if e.Time.Before(st.expires) {
+			st.repeated++
+			st.last = e.Time
+			d.suppressed.Add(1)
+			return
+		}
+		d.emit(st, next)
+	}
+	d.states[key] = &dedupState{first: e, expires: e.Time.Add(d.Window)}
+	next(e)
+}
+
+// flush writes the summaries of the duplicates that are no longer held back.
+func (d *deduper) flush(now time.Time, next func(*Entry)) {
+	if d.Window <= 0 {
+		if d.prev != nil && d.prev.repeated > 0 &&
+			(now.Sub(d.prev.last) >= dedupIdle || now.Sub(d.prev.since) >= dedupRepeat) {
+			d.emit(d.prev, next)
+			d.prev.repeated = 0 // Later duplicates are still collapsed, and counted from zero
+			d.prev.since = now
+		}
+		return
+	}
+	for key, st := range d.states {
+		if !now.Before(st.expires) {
+			d.emit(st, next)
+			delete(d.states, key)
+		}
+	}
+}
+
+// flushAll writes every pending summary, when the logger shuts down.
+func (d *deduper) flushAll(next func(*Entry)) {
+	d.emit(d.prev, next)
+	d.prev = nil
+	for key, st := range d.states {
+		d.emit(st, next)
+		delete(d.states, key)
+	}
+}
+
+func (d *deduper) emit(st *dedupState, next func(*Entry)) {
+	if st == nil || st.repeated == 0 {
+		return
+	}
+	next(&Entry{
+		Time:      st.last,
+		Level:     st.first.Level,
+		Component: st.first.Component,
+		Message:   "last message repeated " + strconv.Itoa(st.repeated) + " times",
+		Fields: []Field{
+			String("repeated_message", st.first.Message),
+			Int("repeated", st.repeated),
+		},
+	})
+}
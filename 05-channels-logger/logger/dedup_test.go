@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// dedupRun feeds entries to a deduper and records what it passes on.
+type dedupRun struct {
+	d   *deduper
+	out []string
+}
+
+func newDedupRun(window time.Duration) *dedupRun {
+	return &dedupRun{d: newDeduper(&Dedup{Window: window, Key: DedupByMessage})}
+}
+
+func (r *dedupRun) next(e *Entry) {
+	r.out = append(r.out, e.Message)
+}
+
+func (r *dedupRun) log(at time.Duration, level Level, msg string) {
+	r.d.filter(&Entry{Time: testTime.Add(at), Level: level, Message: msg}, r.next)
+}
+
+func (r *dedupRun) flush(at time.Duration) {
+	r.d.flush(testTime.Add(at), r.next)
+}
+
+// took returns what was passed on since the last call.
+func (r *dedupRun) took() []string {
+	out := r.out
+	r.out = nil
+	return out
+}
+
+var testTime = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func repeated(n int) string {
+	return fmt.Sprintf("last message repeated %d times", n)
+}
+
+func TestDedupCollapsesConsecutiveDuplicates(t *testing.T) {
+	r := newDedupRun(0)
+	r.log(0, LevelInfo, "a")
+	r.log(1, LevelInfo, "a")
+	r.log(2, LevelInfo, "a")
+	r.log(3, LevelError, "a") // Another level is another entry
+	r.log(4, LevelInfo, "b")
+	r.log(5, LevelInfo, "a")
+	want := []string{"a", repeated(2), "a", "b", "a"}
+	if got := r.took(); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if n := r.d.suppressed.Load(); n != 2 {
+		t.Errorf("suppressed %d entries, want 2", n)
+	}
+}
+
+func TestDedupFlushesConsecutiveDuplicates(t *testing.T) {
+	r := newDedupRun(0)
+	r.log(0, LevelInfo, "a")
+	r.log(100*time.Millisecond, LevelInfo, "a")
+	r.flush(500 * time.Millisecond)
+	if got := r.took(); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("flush before dedupIdle passed on %q", got)
+	}
+	r.flush(100*time.Millisecond + dedupIdle)
+	if got := r.took(); !slices.Equal(got, []string{repeated(1)}) {
+		t.Fatalf("flush after dedupIdle passed on %q", got)
+	}
+
+	// Duplicates that keep coming are summed up every dedupRepeat, each
+	// summary counting from the previous one
+	start := 2 * time.Second
+	r = newDedupRun(0)
+	r.log(start, LevelInfo, "a")
+	var summaries []string
+	for at := start + 500*time.Millisecond; at <= start+70*time.Second; at += 500 * time.Millisecond {
+		r.log(at, LevelInfo, "a")
+		if at%time.Second == 0 {
+			r.flush(at)
+		}
+		summaries = append(summaries, r.took()...)
+	}
+	if want := []string{"a", repeated(60), repeated(60)}; !slices.Equal(summaries, want) {
+		t.Errorf("got %q, want %q", summaries, want)
+	}
+	r.d.flushAll(r.next)
+	if got := r.took(); !slices.Equal(got, []string{repeated(20)}) {
+		t.Errorf("flushAll passed on %q", got)
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	r := newDedupRun(5 * time.Second)
+	r.log(0, LevelInfo, "a")
+	r.log(time.Second, LevelInfo, "a")
+	r.log(2*time.Second, LevelInfo, "b")
+	r.log(3*time.Second, LevelInfo, "a") // Duplicates within the window need not be consecutive
+	r.log(4*time.Second, LevelInfo, "b")
+	if got, want := r.took(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	r.flush(4 * time.Second)
+	if got := r.took(); got != nil {
+		t.Fatalf("flush within the window passed on %q", got)
+	}
+	r.flush(5 * time.Second)
+	if got, want := r.took(), []string{repeated(2)}; !slices.Equal(got, want) {
+		t.Fatalf("flush at the end of the window of a passed on %q, want %q", got, want)
+	}
+	r.log(6*time.Second, LevelInfo, "a") // A new window starts
+	r.log(7*time.Second, LevelInfo, "b") // The window of b ended, but was not flushed yet
+	if got, want := r.took(), []string{"a", repeated(1), "b"}; !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	r.log(8*time.Second, LevelInfo, "a")
+	r.d.flushAll(r.next)
+	if got, want := r.took(), []string{repeated(1)}; !slices.Equal(got, want) {
+		t.Errorf("flushAll passed on %q, want %q", got, want)
+	}
+}
+
+func TestDedupWritesTheSummaryOnClose(t *testing.T) {
+	sink := newGatedSink(100)
+	l := New(WithSink("gated", sink, LevelTrace), WithDedup(Dedup{Window: time.Hour}))
+	for i := 0; i < 5; i++ {
+		l.Warning("disk almost full")
+	}
+	l.Close()
+	want := []string{"disk almost full", repeated(4)}
+	if got := sink.written(); !slices.Equal(got, want) {
+		t.Errorf("sink wrote %q, want %q", got, want)
+	}
+	if n := l.Stats().Suppressed; n != 4 {
+		t.Errorf("Stats reported %d suppressed entries, want 4", n)
+	}
+}
@@ -0,0 +1,423 @@
+package logger
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Encoder turns an entry into bytes. Encode appends the encoded entry,
+// including its trailing newline, to dst and returns the extended slice, so
+// the consumer goroutine can reuse the same buffer for every entry.
+type Encoder interface {
+	Encode(dst []byte, e *Entry) []byte
+}
+
+// TimeFormat configures how encoders write the time of an entry.
+type TimeFormat struct {
+	Layout      string // Layout passed to time.Format, ignored if EpochMillis is set
+	UTC         bool   // Converts the time to UTC before formatting it
+	EpochMillis bool   // Writes milliseconds since the Unix epoch instead
+}
+
+var (
+	// TimeSeconds is the layout used by the original logger() function.
+	TimeSeconds     = TimeFormat{Layout: "2006-01-02T15:04:05"}
+	TimeRFC3339Nano = TimeFormat{Layout: time.RFC3339Nano}
+	TimeUTC         = TimeFormat{Layout: time.RFC3339Nano, UTC: true}
+	TimeEpochMillis = TimeFormat{EpochMillis: true}
+)
+
+func (f TimeFormat) orDefault(def TimeFormat) TimeFormat {
+	if f == (TimeFormat{}) {
+		return def
+	}
+	return f
+}
+
+// AppendTime appends t formatted according to f to dst.
+func (f TimeFormat) AppendTime(dst []byte, t time.Time) []byte {
+	if f.EpochMillis {
+		return strconv.AppendInt(dst, t.UnixMilli(), 10)
+	}
+	if f.UTC {
+		t = t.UTC()
+	}
+	return t.AppendFormat(dst, f.Layout)
+}
+
+// TextEncoder writes entries in the format of the original logger() function:
+//
+//	2006-01-02T15:04:05 - [INFO] message key=value
+//
+// Newlines and other control characters in the message are escaped, so every
+// entry takes exactly one line.
+type TextEncoder struct {
+	Time  TimeFormat // Defaults to TimeSeconds
+	Color bool       // Colors the level with ANSI escape codes, for terminals
+}
+
+func (enc *TextEncoder) Encode(dst []byte, e *Entry) []byte {
+	dst = enc.Time.orDefault(TimeSeconds).AppendTime(dst, e.Time)
+	dst = append(dst, " - ["...)
+	if enc.Color {
+		dst = append(dst, levelColor(e.Level)...)
+		dst = append(dst, e.Level.String()...)
+		dst = append(dst, colorReset...)
+	} else {
+		dst = append(dst, e.Level.String()...)
+	}
+	dst = append(dst, "] "...)
+	dst = appendEscaped(dst, e.Message)
+	if e.Component != "" {
+		dst = append(dst, " component="...)
+		dst = appendLogfmtString(dst, e.Component)
+	}
+	for _, f := range e.allFields() {
+		dst = append(dst, ' ')
+		dst = appendLogfmtField(dst, f)
+	}
+	return append(dst, '\n')
+}
+
+const colorReset = "\x1b[0m"
+
+func levelColor(l Level) string {
+	switch {
+	case l >= LevelFatal:
+		return "\x1b[35m" // Magenta
+	case l >= LevelError:
+		return "\x1b[31m" // Red
+	case l >= LevelWarning:
+		return "\x1b[33m" // Yellow
+	case l >= LevelInfo:
+		return "\x1b[36m" // Cyan
+	}
+	return "\x1b[90m" // Gray
+}
+
+// JSONEncoder writes one JSON object per line:
+//
+//	{"time":"2006-01-02T15:04:05.999999999Z07:00","level":"INFO","msg":"message","key":"value"}
+type JSONEncoder struct {
+	Time TimeFormat // Defaults to TimeRFC3339Nano
+}
+
+func (enc *JSONEncoder) Encode(dst []byte, e *Entry) []byte {
+	tf := enc.Time.orDefault(TimeRFC3339Nano)
+	dst = append(dst, `{"time":`...)
+	if tf.EpochMillis {
+		dst = tf.AppendTime(dst, e.Time)
+	} else {
+		dst = append(dst, '"')
+		dst = tf.AppendTime(dst, e.Time) // Layouts never produce characters that need escaping
+		dst = append(dst, '"')
+	}
+	dst = append(dst, `,"level":"`...)
+	dst = append(dst, e.Level.String()...)
+	dst = append(dst, `","msg":`...)
+	dst = appendJSONString(dst, e.Message)
+	if e.Component != "" {
+		dst = append(dst, `,"component":`...)
+		dst = appendJSONString(dst, e.Component)
+	}
+	for _, f := range e.allFields() {
+		dst = append(dst, ',')
+		dst = appendJSONString(dst, f.Key)
+		dst = append(dst, ':')
+		dst = appendJSONField(dst, f)
+	}
+	return append(dst, "}\n"...)
+}
+
+// LogfmtEncoder writes entries as space-separated key=value pairs:
+//
+//	time=2006-01-02T15:04:05.999999999Z07:00 level=INFO msg="some message" key=value
+type LogfmtEncoder struct {
+	Time TimeFormat // Defaults to TimeRFC3339Nano
+}
+
+func (enc *LogfmtEncoder) Encode(dst []byte, e *Entry) []byte {
+	dst = append(dst, "time="...)
+	dst = enc.Time.orDefault(TimeRFC3339Nano).AppendTime(dst, e.Time)
+	dst = append(dst, " level="...)
+	dst = append(dst, e.Level.String()...)
+	dst = append(dst, " msg="...)
+	dst = appendLogfmtString(dst, e.Message)
+	if e.Component != "" {
+		dst = append(dst, " component="...)
+		dst = appendLogfmtString(dst, e.Component)
+	}
+	for _, f := range e.allFields() {
+		dst = append(dst, ' ')
+		dst = appendLogfmtField(dst, f)
+	}
+	return append(dst, '\n')
+}
+
+// formatValue converts a field value to the string written by the text and
+// logfmt encoders.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return "<nil>"
+	case error:
+		return v.Error()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return safeString(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// safeString calls v.String, turning a panic into text like fmt does, so a
+// broken Stringer cannot bring down the goroutine of a sink.
+func safeString(v fmt.Stringer) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			s = fmt.Sprintf("%%!PANIC=String method: %v", r)
+		}
+	}()
+	return v.String()
+}
+
+// appendScalar appends the value of the numeric, bool, time and duration
+// fields, whose text never needs quoting or escaping, without allocating. It
+// reports false, appending nothing, for the other kinds.
+func appendScalar(dst []byte, f Field) ([]byte, bool) {
+	switch f.Kind {
+	case KindInt64:
+		return strconv.AppendInt(dst, f.num, 10), true
+	case KindUint64:
+		return strconv.AppendUint(dst, uint64(f.num), 10), true
+	case KindFloat64:
+		return strconv.AppendFloat(dst, math.Float64frombits(uint64(f.num)), 'g', -1, 64), true
+	case KindBool:
+		return strconv.AppendBool(dst, f.num != 0), true
+	case KindDuration:
+		return append(dst, time.Duration(f.num).String()...), true
+	case KindTime:
+		return f.time().AppendFormat(dst, time.RFC3339Nano), true
+	}
+	return dst, false
+}
+
+// text returns the value of the field as the text and logfmt encoders write
+// it, before quoting.
+func (f Field) text() string {
+	switch f.Kind {
+	case KindString:
+		return f.str
+	case KindStringer:
+		return safeString(f.any.(fmt.Stringer))
+	}
+	if b, ok := appendScalar(nil, f); ok {
+		return string(b)
+	}
+	return formatValue(f.any)
+}
+
+func appendLogfmtField(dst []byte, f Field) []byte {
+	dst = appendLogfmtKey(dst, f.Key)
+	dst = append(dst, '=')
+	if f.Kind == KindString {
+		return appendLogfmtString(dst, f.str)
+	}
+	if b, ok := appendScalar(dst, f); ok {
+		return b
+	}
+	return appendLogfmtString(dst, f.text())
+}
+
+// appendLogfmtKey drops the characters that would make a key ambiguous.
+func appendLogfmtKey(dst []byte, key string) []byte {
+	if key == "" {
+		return append(dst, '_')
+	}
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			r = '_'
+		}
+		dst = utf8.AppendRune(dst, r)
+	}
+	return dst
+}
+
+// appendLogfmtString quotes s only when it would otherwise be ambiguous.
+func appendLogfmtString(dst []byte, s string) []byte {
+	if !needsQuoting(s) {
+		return append(dst, s...)
+	}
+	dst = append(dst, '"')
+	dst = appendEscaped(dst, s)
+	return append(dst, '"')
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// appendEscaped writes s with backslashes, double quotes and control
+// characters escaped the same way as Go string literals.
+func appendEscaped(dst []byte, s string) []byte {
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			i++
+			switch {
+			case b == '\\' || b == '"':
+				dst = append(dst, '\\', b)
+			case b == '\n':
+				dst = append(dst, '\\', 'n')
+			case b == '\r':
+				dst = append(dst, '\\', 'r')
+			case b == '\t':
+				dst = append(dst, '\\', 't')
+			case b < ' ' || b == 0x7f:
+				dst = append(dst, '\\', 'x', hex[b>>4], hex[b&0xf])
+			default:
+				dst = append(dst, b)
+			}
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, '\\', 'x', hex[b>>4], hex[b&0xf])
+		} else if r == '\u2028' || r == '\u2029' || (r >= 0x80 && r < 0xa0) {
+			dst = append(dst, `\u`...)
+			dst = append(dst, hex[r>>12&0xf], hex[r>>8&0xf], hex[r>>4&0xf], hex[r&0xf])
+		} else {
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return dst
+}
+
+const hex = "0123456789abcdef"
+
+// appendJSONString writes s as a JSON string. Invalid UTF-8 is replaced
+// with U+FFFD so the output is always valid JSON.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			i++
+			switch {
+			case b == '\\' || b == '"':
+				dst = append(dst, '\\', b)
+			case b == '\n':
+				dst = append(dst, '\\', 'n')
+			case b == '\r':
+				dst = append(dst, '\\', 'r')
+			case b == '\t':
+				dst = append(dst, '\\', 't')
+			case b < ' ':
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xf])
+			default:
+				dst = append(dst, b)
+			}
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, `\ufffd`...)
+		case r == '\u2028' || r == '\u2029': // Valid JSON, but they break JavaScript parsers
+			dst = append(dst, `\u202`...)
+			dst = append(dst, hex[r&0xf])
+		default:
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
+
+func appendJSONField(dst []byte, f Field) []byte {
+	switch f.Kind {
+	case KindString:
+		return appendJSONString(dst, f.str)
+	case KindInt64, KindUint64, KindBool:
+		dst, _ = appendScalar(dst, f)
+		return dst
+	case KindFloat64:
+		return appendJSONFloat(dst, math.Float64frombits(uint64(f.num)), 64)
+	case KindTime, KindDuration:
+		dst = append(dst, '"')
+		dst, _ = appendScalar(dst, f) // Neither needs escaping
+		return append(dst, '"')
+	case KindStringer:
+		return appendJSONString(dst, f.text())
+	}
+	return appendJSONValue(dst, f.any)
+}
+
+func appendJSONValue(dst []byte, v any) []byte {
+	switch v := v.(type) {
+	case string:
+		return appendJSONString(dst, v)
+	case nil:
+		return append(dst, "null"...)
+	case bool:
+		return strconv.AppendBool(dst, v)
+	case int:
+		return strconv.AppendInt(dst, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(dst, v, 10)
+	case int32:
+		return strconv.AppendInt(dst, int64(v), 10)
+	case uint:
+		return strconv.AppendUint(dst, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(dst, v, 10)
+	case uint32:
+		return strconv.AppendUint(dst, uint64(v), 10)
+	case float64:
+		return appendJSONFloat(dst, v, 64)
+	case float32:
+		return appendJSONFloat(dst, float64(v), 32)
+	case time.Time:
+		return appendJSONString(dst, v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return appendJSONString(dst, v.String())
+	case json.Marshaler, encoding.TextMarshaler:
+		// Let encoding/json deal with these below
+	case error:
+		return appendJSONString(dst, v.Error())
+	case fmt.Stringer:
+		return appendJSONString(dst, safeString(v))
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return appendJSONString(dst, fmt.Sprint(v))
+	}
+	return append(dst, b...)
+}
+
+// appendJSONFloat writes NaN and infinities as strings, since JSON has no
+// literal for them.
+func appendJSONFloat(dst []byte, f float64, bits int) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		dst = append(dst, '"')
+		dst = strconv.AppendFloat(dst, f, 'g', -1, bits)
+		return append(dst, '"')
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, bits)
+}
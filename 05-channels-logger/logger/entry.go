@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// FieldKind tells which member of a Field holds its value.
+type FieldKind uint8
+
+const (
+	KindAny FieldKind = iota
+	KindString
+	KindInt64
+	KindUint64
+	KindFloat64
+	KindBool
+	KindTime
+	KindDuration
+	KindError
+	KindStringer // Formatted by the encoder, on the sink goroutine
+)
+
+// Field is a key/value pair attached to an entry. The typed constructors
+// store the value without boxing it in an interface, so building a field,
+// even for a disabled level, never allocates. Value returns it as an
+// interface when one is needed.
+type Field struct {
+	Key  string
+	Kind FieldKind
+	num  int64  // Int64, Uint64, Float64 bits, Bool, Duration, or Time in Unix nanoseconds
+	str  string // String
+	any  any    // Any, Error, Stringer, or the *time.Location of a Time
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, Kind: KindString, str: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, Kind: KindInt64, num: int64(value)}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Kind: KindInt64, num: value}
+}
+
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, Kind: KindUint64, num: int64(value)}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, Kind: KindFloat64, num: int64(math.Float64bits(value))}
+}
+
+func Bool(key string, value bool) Field {
+	var n int64
+	if value {
+		n = 1
+	}
+	return Field{Key: key, Kind: KindBool, num: n}
+}
+
+func Time(key string, value time.Time) Field {
+	if y := value.Year(); y < 1678 || y > 2261 {
+		return Field{Key: key, any: value} // Out of the range of UnixNano
+	}
+	return Field{Key: key, Kind: KindTime, num: value.UnixNano(), any: value.Location()}
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Kind: KindDuration, num: int64(value)}
+}
+
+// Err stores err under the "error" key.
+func Err(err error) Field {
+	return Field{Key: "error", Kind: KindError, any: err}
+}
+
+// Stringer stores value and calls its String method only when the entry is
+// encoded, on the goroutine of the sink. Use it for values that are costly
+// to format, and only for values that are not modified after the call.
+func Stringer(key string, value fmt.Stringer) Field {
+	return Field{Key: key, Kind: KindStringer, any: value}
+}
+
+// Any stores a value of any type, using the typed representation for the
+// types that have one.
+func Any(key string, value any) Field {
+	switch v := value.(type) {
+	case string:
+		return String(key, v)
+	case int:
+		return Int(key, v)
+	case int64:
+		return Int64(key, v)
+	case uint64:
+		return Uint64(key, v)
+	case float64:
+		return Float64(key, v)
+	case bool:
+		return Bool(key, v)
+	case time.Time:
+		return Time(key, v)
+	case time.Duration:
+		return Duration(key, v)
+	case error:
+		return Field{Key: key, Kind: KindError, any: v}
+	}
+	return Field{Key: key, any: value}
+}
+
+// Value returns the value of the field. It allocates for most kinds, so the
+// encoders use the typed members instead.
+func (f Field) Value() any {
+	switch f.Kind {
+	case KindString:
+		return f.str
+	case KindInt64:
+		return f.num
+	case KindUint64:
+		return uint64(f.num)
+	case KindFloat64:
+		return math.Float64frombits(uint64(f.num))
+	case KindBool:
+		return f.num != 0
+	case KindTime:
+		return f.time()
+	case KindDuration:
+		return time.Duration(f.num)
+	}
+	return f.any
+}
+
+func (f Field) time() time.Time {
+	return time.Unix(0, f.num).In(f.any.(*time.Location))
+}
+
+// inlineFields is the number of fields an entry holds without allocating a
+// separate slice for them.
+const inlineFields = 4
+
+// Entry is the unit sent through the logger channel. It is the exported
+// equivalent of the logEntry struct, plus the fields of the logger that
+// created it.
+type Entry struct {
+	Time      time.Time
+	Level     Level
+	Component string // Set by loggers created with Named
+	Message   string
+	Fields    []Field
+	Caller    Caller    // Set with WithCaller
+	Stack     string    // Set for levels at or above WithStackLevel
+	Span      SpanEvent // Set by StartSpan and Span.End
+
+	fields [inlineFields]Field // Backs Fields when they fit, so an entry is a single allocation
+}
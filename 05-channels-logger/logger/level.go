@@ -0,0 +1,176 @@
+package logger
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is the severity of an entry. Levels are ordered, so they can be
+// compared to decide whether an entry should be written or not.
+type Level int8
+
+const (
+	LevelTrace Level = iota - 2
+	LevelDebug
+	LevelInfo // The zero value
+	LevelWarning
+	LevelError
+	LevelFatal
+)
+
+// numLevels is the number of defined levels, used to size per-level counters.
+const numLevels = int(LevelFatal-LevelTrace) + 1
+
+func levelIndex(l Level) int {
+	return min(max(int(l-LevelTrace), 0), numLevels-1)
+}
+
+func levelAt(i int) Level {
+	return LevelTrace + Level(i)
+}
+
+var levelNames = [numLevels]string{"TRACE", "DEBUG", "INFO", "WARNING", "ERROR", "FATAL"}
+
+func (l Level) String() string {
+	if l < LevelTrace || l > LevelFatal {
+		return fmt.Sprintf("LEVEL(%d)", int8(l))
+	}
+	return levelNames[l-LevelTrace]
+}
+
+// ParseLevel converts a level name into a Level. It ignores case and also
+// accepts "warn", "err" and "crit".
+func ParseLevel(s string) (Level, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	for i, n := range levelNames {
+		if name == n {
+			return levelAt(i), nil
+		}
+	}
+	switch name {
+	case "WARN":
+		return LevelWarning, nil
+	case "ERR":
+		return LevelError, nil
+	case "CRIT", "CRITICAL":
+		return LevelFatal, nil
+	}
+	return LevelInfo, fmt.Errorf("logger: unknown level %q", s)
+}
+
+// MarshalText makes levels readable in JSON and any other text format.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	parsed, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+// levels holds the minimum levels of a logger. Both can be replaced at any
+// time without locking the producers.
+type levels struct {
+	min        atomic.Int32
+	components atomic.Pointer[map[string]Level]
+}
+
+func (lv *levels) enabled(level Level, component string) bool {
+	if component != "" {
+		if overrides := lv.components.Load(); overrides != nil {
+			if min, ok := (*overrides)[component]; ok {
+				return level >= min
+			}
+		}
+	}
+	return int32(level) >= lv.min.Load()
+}
+
+// ParseLevels parses a comma-separated list of component=level pairs, such
+// as "db=debug,http=warn". An entry without a component, or with "*" as the
+// component, sets the global minimum level, which is returned as min when
+// hasMin is true.
+func ParseLevels(spec string) (min Level, hasMin bool, components map[string]Level, err error) {
+	components = make(map[string]Level)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, found := strings.Cut(part, "=")
+		if !found {
+			name, value = "*", name
+		}
+		level, err := ParseLevel(value)
+		if err != nil {
+			return LevelInfo, false, nil, err
+		}
+		if name = strings.TrimSpace(name); name == "*" {
+			min, hasMin = level, true
+		} else {
+			components[name] = level
+		}
+	}
+	return min, hasMin, components, nil
+}
+
+// WithLevel sets the global minimum level. Defaults to LevelInfo.
+func WithLevel(min Level) Option {
+	return func(c *config) {
+		c.level = min
+	}
+}
+
+// WithLevels applies a spec in the format of ParseLevels, e.g.
+// "info,db=debug,http=warn". It panics if spec is invalid, like a regexp
+// passed to regexp.MustCompile, so a spec that comes from a flag or the
+// environment should be checked with ParseLevels first.
+func WithLevels(spec string) Option {
+	min, hasMin, components, err := ParseLevels(spec)
+	if err != nil {
+		panic("logger: WithLevels: " + err.Error())
+	}
+	return func(c *config) {
+		if hasMin {
+			c.level = min
+		}
+		c.components = maps.Clone(components) // The Option may be passed to several loggers
+	}
+}
+
+// SetLevel changes the global minimum level. It affects every logger sharing
+// the same consumer goroutine, and is safe to call while logging.
+func (l *Logger) SetLevel(min Level) {
+	l.core.levels.min.Store(int32(min))
+}
+
+// Level returns the global minimum level.
+func (l *Logger) Level() Level {
+	return Level(l.core.levels.min.Load())
+}
+
+// SetComponentLevels replaces every per-component override with the ones in
+// spec, in the format of ParseLevels. A global level in spec is applied too.
+func (l *Logger) SetComponentLevels(spec string) error {
+	min, hasMin, components, err := ParseLevels(spec)
+	if err != nil {
+		return err
+	}
+	if hasMin {
+		l.SetLevel(min)
+	}
+	l.core.levels.components.Store(&components)
+	return nil
+}
+
+// Enabled reports whether an entry at level would be sent by this logger.
+// Use it to skip building expensive fields for entries that are filtered out.
+func (l *Logger) Enabled(level Level) bool {
+	return l.core.levels.enabled(level, l.component)
+}
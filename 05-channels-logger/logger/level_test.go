@@ -0,0 +1,27 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWithLevels(t *testing.T) {
+	l := New(WithLevels("warn,db=debug"))
+	defer l.Close()
+	if l.Level() != LevelWarning {
+		t.Errorf("level is %s, want WARNING", l.Level())
+	}
+	if !l.Named("db").Enabled(LevelDebug) || l.Named("http").Enabled(LevelInfo) {
+		t.Error("the component levels were not applied")
+	}
+}
+
+func TestWithLevelsPanicsOnAnInvalidSpec(t *testing.T) {
+	defer func() {
+		r := recover()
+		if msg, _ := r.(string); !strings.Contains(msg, "verbose") {
+			t.Errorf("WithLevels panicked with %v, want the error of ParseLevels", r)
+		}
+	}()
+	WithLevels("info,db=verbose")
+}
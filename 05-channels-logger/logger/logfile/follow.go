@@ -0,0 +1,153 @@
+package logfile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxLine is the longest line Scan and Follow accept.
+const maxLine = 1 << 20
+
+// Open opens a log file for reading, decompressing it if its name ends in
+// .gz like the files rotated by logger.RotatingFileSink.
+func Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{zr, f}, nil
+}
+
+// Scan calls fn for every line in r, without its newline. It stops at the
+// first error returned by fn.
+func Scan(r io.Reader, fn func(line []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), maxLine)
+	for sc.Scan() {
+		if err := fn(sc.Bytes()); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// pollInterval is how often Follow checks for new lines and rotations.
+const pollInterval = 250 * time.Millisecond
+
+// Follow calls fn for every line appended to path, like tail -F, until ctx is
+// done or fn returns an error. It starts at the end of the file, or at the
+// beginning if fromStart is set or the file does not exist yet. When the file
+// is renamed or deleted, the rest of it is read before switching to the new
+// file at path, so no line is lost to a rotation. A file that shrinks is read
+// again from the beginning.
+func Follow(ctx context.Context, path string, fromStart bool, fn func(line []byte) error) error {
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	var r *bufio.Reader
+	var partial []byte // A line still being written
+	var offset int64
+
+	readLines := func() error {
+		for {
+			chunk, err := r.ReadSlice('\n')
+			offset += int64(len(chunk))
+			if err == bufio.ErrBufferFull {
+				partial = append(partial, chunk...) // Very long line, keep reading
+				continue
+			}
+			if err != nil {
+				partial = append(partial, chunk...)
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			line := chunk[:len(chunk)-1]
+			if len(partial) > 0 {
+				line = append(partial, line...)
+				partial = partial[:0]
+			}
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		if f == nil {
+			var err error
+			f, err = os.Open(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				fromStart = true // A file that appears later is new, so it is read whole
+			} else if err != nil {
+				return err
+			}
+			if f != nil {
+				offset = 0
+				if !fromStart {
+					if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+						return err
+					}
+				}
+				fromStart = true // As are the files that replace it
+				r = bufio.NewReaderSize(f, 64<<10)
+				partial = partial[:0]
+			}
+		}
+		if f != nil {
+			if err := readLines(); err != nil {
+				return err
+			}
+			current, err := os.Stat(path)
+			open, serr := f.Stat()
+			switch {
+			case serr != nil:
+				return serr
+			case err != nil || !os.SameFile(current, open):
+				// Rotated away: what is left was read above, unless the writer
+				// managed to add more since, so read once more
+				if err := readLines(); err != nil {
+					return err
+				}
+				f.Close()
+				f = nil
+				continue
+			case open.Size() < offset:
+				// Truncated in place
+				if _, err := f.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				offset = 0
+				r.Reset(f)
+				partial = partial[:0]
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,351 @@
+// Package logfile reads the files written by the logger package back into
+// entries. It understands the text, JSON and logfmt encoders, and can follow
+// a file that is being written and rotated.
+package logfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+)
+
+// ErrUnknownFormat is returned by Parse for lines that none of the
+// encoders could have written.
+var ErrUnknownFormat = errors.New("logfile: unknown line format")
+
+// Parse detects the format of a line and converts it back into an entry.
+// Field values come back as strings, or as the JSON values of JSON lines,
+// with numbers as Int64, Uint64 or Float64 fields.
+func Parse(line []byte) (*logger.Entry, error) {
+	line = bytes.TrimRight(line, "\r\n")
+	var e *logger.Entry
+	var err error
+	switch {
+	case len(line) > 0 && line[0] == '{':
+		e, err = parseJSON(line)
+	case bytes.HasPrefix(line, []byte("time=")):
+		e, err = parseLogfmt(line)
+	case bytes.Contains(line, []byte(" - [")):
+		e, err = parseText(line)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	liftMeta(e)
+	return e, nil
+}
+
+// liftMeta moves the fields written for logger.WithCaller,
+// logger.WithStackLevel and spans back into the entry.
+func liftMeta(e *logger.Entry) {
+	fields := e.Fields[:0]
+	for _, f := range e.Fields {
+		value, ok := metaText(f)
+		switch {
+		case !ok:
+		case f.Key == "caller":
+			i := strings.LastIndexByte(value, ':')
+			n, err := strconv.Atoi(value[i+1:])
+			if i > 0 && err == nil {
+				e.Caller.File, e.Caller.Line = value[:i], n
+				continue
+			}
+		case f.Key == "func":
+			e.Caller.Function = value
+			continue
+		case f.Key == "stack":
+			e.Stack = value
+			continue
+		case f.Key == "span" && value == "begin":
+			e.Span.Phase = logger.SpanBegin
+			continue
+		case f.Key == "span" && value == "end":
+			e.Span.Phase = logger.SpanEnd
+			continue
+		case f.Key == "span_id" || f.Key == "goroutine":
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				break
+			}
+			if f.Key == "span_id" {
+				e.Span.ID = n
+			} else {
+				e.Span.Goroutine = n
+			}
+			continue
+		}
+		fields = append(fields, f)
+	}
+	e.Fields = fields
+}
+
+// metaText returns the text of a field liftMeta may lift. Numbers read from
+// JSON lines are Int64 or Uint64 fields.
+func metaText(f logger.Field) (string, bool) {
+	switch v := f.Value().(type) {
+	case string:
+		return v, true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	return "", false
+}
+
+// textLayouts are tried in order for the time of text lines.
+var textLayouts = []string{"2006-01-02T15:04:05", time.RFC3339Nano}
+
+func parseTime(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil // TimeEpochMillis
+	}
+	var err error
+	for _, layout := range textLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// ParseTimeFlag parses the times given to the commands that read log files:
+// an absolute time such as 2024-05-01T10:00:00, in the local time zone unless
+// it has an offset, or a duration such as 15m meaning that long ago. The
+// empty string is the zero time.
+func ParseTimeFlag(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse time %q", s)
+}
+
+// parseText parses "2006-01-02T15:04:05 - [INFO] message key=value".
+func parseText(line []byte) (*logger.Entry, error) {
+	ts, rest, _ := strings.Cut(string(line), " - [")
+	levelName, rest, ok := strings.Cut(rest, "] ")
+	if !ok {
+		levelName, ok = strings.CutSuffix(rest, "]") // Empty message
+		rest = ""
+		if !ok {
+			return nil, ErrUnknownFormat
+		}
+	}
+	t, err := parseTime(ts)
+	if err != nil {
+		return nil, fmt.Errorf("logfile: bad time %q: %w", ts, err)
+	}
+	level, err := logger.ParseLevel(stripColor(levelName))
+	if err != nil {
+		return nil, err
+	}
+	e := &logger.Entry{Time: t, Level: level}
+
+	// Fields follow the message, but the message is not quoted, so the fields
+	// are the longest run of key=value pairs at the end of the line.
+	tokens := tokenize(rest)
+	start := len(tokens)
+	for start > 0 && tokens[start-1].isPair {
+		start--
+	}
+	msgEnd := len(rest)
+	if start < len(tokens) {
+		msgEnd = tokens[start].offset
+	}
+	e.Message = unescape(strings.TrimRight(rest[:msgEnd], " "))
+	for _, tok := range tokens[start:] {
+		if tok.key == "component" && e.Component == "" {
+			e.Component = tok.value
+			continue
+		}
+		e.Fields = append(e.Fields, logger.String(tok.key, tok.value))
+	}
+	return e, nil
+}
+
+// stripColor removes the ANSI codes written by TextEncoder{Color: true}.
+func stripColor(s string) string {
+	for {
+		i := strings.Index(s, "\x1b[")
+		if i < 0 {
+			return s
+		}
+		j := strings.IndexByte(s[i:], 'm')
+		if j < 0 {
+			return s
+		}
+		s = s[:i] + s[i+j+1:]
+	}
+}
+
+// parseLogfmt parses "time=... level=INFO msg=... key=value".
+func parseLogfmt(line []byte) (*logger.Entry, error) {
+	e := &logger.Entry{}
+	for _, tok := range tokenize(string(line)) {
+		if !tok.isPair {
+			return nil, fmt.Errorf("logfile: bad logfmt token %q", tok.value)
+		}
+		var err error
+		switch tok.key {
+		case "time":
+			e.Time, err = parseTime(tok.value)
+		case "level":
+			e.Level, err = logger.ParseLevel(tok.value)
+		case "msg":
+			e.Message = tok.value
+		case "component":
+			e.Component = tok.value
+		default:
+			e.Fields = append(e.Fields, logger.String(tok.key, tok.value))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// parseJSON parses a line written by JSONEncoder, keeping the fields in the
+// order they were written.
+func parseJSON(line []byte) (*logger.Entry, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil { // The opening brace
+		return nil, err
+	}
+	e := &logger.Entry{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		switch key {
+		case "time":
+			e.Time, err = parseTime(fmt.Sprint(value))
+		case "level":
+			e.Level, err = logger.ParseLevel(fmt.Sprint(value))
+		case "msg":
+			e.Message = fmt.Sprint(value)
+		case "component":
+			e.Component = fmt.Sprint(value)
+		default:
+			e.Fields = append(e.Fields, jsonField(key, value))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// jsonField stores numbers as Int64, Uint64 or Float64 fields, so they are
+// encoded as numbers again rather than as quoted json.Number values.
+func jsonField(key string, value any) logger.Field {
+	n, ok := value.(json.Number)
+	if !ok {
+		return logger.Any(key, value)
+	}
+	if i, err := n.Int64(); err == nil {
+		return logger.Int64(key, i)
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return logger.Uint64(key, u)
+	}
+	if f, err := n.Float64(); err == nil {
+		return logger.Float64(key, f)
+	}
+	return logger.String(key, string(n))
+}
+
+type token struct {
+	offset int // Where the token starts in the line
+	isPair bool
+	key    string
+	value  string // Unquoted
+}
+
+// tokenize splits a line into space-separated tokens, keeping quoted values
+// together.
+func tokenize(s string) []token {
+	var tokens []token
+	for i := 0; i < len(s); {
+		if s[i] == ' ' {
+			i++
+			continue
+		}
+		start := i
+		eq := -1
+		for i < len(s) && s[i] != ' ' {
+			if s[i] == '=' && eq < 0 {
+				eq = i
+				if i+1 < len(s) && s[i+1] == '"' {
+					i = skipQuoted(s, i+1)
+					continue
+				}
+			}
+			i++
+		}
+		raw := s[start:i]
+		tok := token{offset: start, value: raw}
+		if eq > start {
+			tok.isPair = true
+			tok.key = s[start:eq]
+			tok.value = s[eq+1 : i]
+			if strings.HasPrefix(tok.value, `"`) {
+				if v, err := strconv.Unquote(tok.value); err == nil {
+					tok.value = v
+				}
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+// skipQuoted returns the index just after the quoted string starting at i.
+func skipQuoted(s string, i int) int {
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// unescape reverses the escaping that TextEncoder applies to messages.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	if u, err := strconv.Unquote(`"` + s + `"`); err == nil {
+		return u
+	}
+	return s
+}
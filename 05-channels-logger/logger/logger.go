@@ -0,0 +1,328 @@
+// Package logger is an importable version of the channel logger from
+// 05-channels-logger. Entries are sent through a buffered channel and handed
+// to the sinks by a single consumer goroutine, so producers never write to
+// the output directly and entries come out in the order they were sent.
+package logger
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Logger sends entries to its consumer goroutine. Loggers created with With
+// share the same channel and goroutine as their parent.
+type Logger struct {
+	core      *core
+	component string
+	fields    []Field
+	skip      int // Extra frames to skip when looking for the caller, see AddCallerSkip
+}
+
+// core is the state shared by a logger and all of its children.
+type core struct {
+	ch      chan *Entry
+	sinks   []*sinkRunner
+	done    chan struct{} // Closed when the consumer goroutine and sinks exit
+	drained chan struct{} // Closed when the consumer goroutine stops handing entries to the sinks
+
+	mtx    sync.RWMutex // Guards closed, so nothing is sent on a closed channel
+	closed bool
+
+	closing   chan struct{} // Closed when Shutdown starts, wakes up blocked producers
+	abort     chan struct{} // Closed when Shutdown gives up, the consumer stops writing
+	closeOnce sync.Once
+	abortOnce sync.Once
+	accepted  atomic.Int64 // Entries that made it into the channel
+	written   atomic.Int64 // Entries the consumer has handed to the sinks
+	counts    entryCounts
+
+	backpressure Backpressure
+	noDrop       Level
+	sampled      atomic.Int64 // Entries seen by the Sample policy
+	protected    atomic.Int64 // Entries protected by WithNoDrop on their way through the channel, see countsProtected
+	popMtx       sync.Mutex   // Guards protected against DropOldest taking the head of the channel
+	dropped      [numLevels]atomic.Int64
+
+	levels      levels
+	redactor    *redactor
+	dedup       *deduper
+	contextKeys []contextKeyField
+	caller      bool
+	stackLevel  Level
+}
+
+type config struct {
+	out          io.Writer
+	enc          Encoder
+	bufferSize   int
+	backpressure Backpressure
+	noDrop       Level
+	sinks        []sinkConfig
+	sinkTimeout  time.Duration
+	level        Level
+	components   map[string]Level
+	redaction    *Redaction
+	dedup        *Dedup
+	contextKeys  []contextKeyField
+	caller       bool
+	stackLevel   Level
+}
+
+// Option configures a Logger created with New.
+type Option func(*config)
+
+// WithOutput sets where the default sink writes entries. Defaults to
+// os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(c *config) {
+		c.out = w
+	}
+}
+
+// WithEncoder sets how the default sink formats entries. Defaults to a
+// TextEncoder, which keeps the format of the original logger() function.
+func WithEncoder(enc Encoder) Option {
+	return func(c *config) {
+		c.enc = enc
+	}
+}
+
+// WithBufferSize sets the capacity of the entry channel. Defaults to 50.
+func WithBufferSize(n int) Option {
+	return func(c *config) {
+		c.bufferSize = n
+	}
+}
+
+// New creates a Logger and starts its consumer goroutine. Close or Shutdown
+// must be called to flush the buffered entries and stop the goroutine.
+func New(opts ...Option) *Logger {
+	cfg := config{
+		out:         os.Stdout,
+		enc:         &TextEncoder{},
+		bufferSize:  50,
+		noDrop:      levelNone,
+		sinkTimeout: time.Second,
+		contextKeys: append([]contextKeyField(nil), defaultContextKeys...),
+		stackLevel:  LevelError,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if len(cfg.sinks) == 0 {
+		cfg.sinks = []sinkConfig{{"default", NewWriterSink(cfg.out, cfg.enc), LevelTrace}}
+	}
+	c := &core{
+		ch:      make(chan *Entry, cfg.bufferSize),
+		done:    make(chan struct{}),
+		drained: make(chan struct{}),
+		closing: make(chan struct{}),
+		abort:   make(chan struct{}),
+
+		backpressure: cfg.backpressure,
+		noDrop:       cfg.noDrop,
+		contextKeys:  cfg.contextKeys,
+		caller:       cfg.caller,
+		stackLevel:   cfg.stackLevel,
+	}
+	c.levels.min.Store(int32(cfg.level))
+	if cfg.components != nil {
+		c.levels.components.Store(&cfg.components)
+	}
+	if cfg.redaction != nil {
+		c.redactor = newRedactor(cfg.redaction)
+	}
+	if cfg.dedup != nil {
+		c.dedup = newDeduper(cfg.dedup)
+	}
+	for _, sc := range cfg.sinks {
+		c.sinks = append(c.sinks, newSinkRunner(sc, cfg.bufferSize, cfg.sinkTimeout))
+	}
+	go c.run()
+	return &Logger{core: c}
+}
+
+func (c *core) run() {
+	defer close(c.done)
+	var wg sync.WaitGroup
+	for _, s := range c.sinks {
+		wg.Add(1)
+		go s.run(c.abort, &wg)
+	}
+	var tick <-chan time.Time // Nil, so it never fires, unless a stage needs it
+	if c.dedup != nil {
+		ticker := time.NewTicker(c.dedup.interval())
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+loop:
+	for {
+		select {
+		case entry, ok := <-c.ch:
+			if !ok {
+				break loop // Shutdown closed the channel and it is empty
+			}
+			if c.countsProtected() && entry.Level >= c.noDrop {
+				c.protected.Add(-1)
+			}
+			select {
+			case <-c.abort:
+				continue // Shutdown already reported this entry as lost
+			default:
+			}
+			c.counts.add(entry)
+			c.process(entry)
+			c.written.Add(1)
+		case now := <-tick:
+			c.dedup.flush(now, c.dispatch)
+		}
+	}
+	if c.dedup != nil {
+		c.dedup.flushAll(c.dispatch)
+	}
+	for _, s := range c.sinks {
+		close(s.ch)
+	}
+	close(c.drained)
+	wg.Wait()
+}
+
+// process runs the stages in front of the sinks, then dispatches the entry.
+func (c *core) process(entry *Entry) {
+	if c.redactor != nil {
+		c.redactor.redact(entry)
+	}
+	if c.dedup != nil && entry.Span.Phase == SpanNone { // Span events are never duplicates
+		c.dedup.filter(entry, c.dispatch)
+		return
+	}
+	c.dispatch(entry)
+}
+
+// dispatch fans an entry out to every sink that wants its level.
+func (c *core) dispatch(entry *Entry) {
+	for _, s := range c.sinks {
+		if entry.Level >= s.min {
+			s.offer(entry, c.abort)
+		}
+	}
+}
+
+func (c *core) send(entry *Entry) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.closed {
+		return // Entries sent after Shutdown are discarded
+	}
+	c.accepted.Add(1)
+	if !c.enqueue(entry) {
+		c.accepted.Add(-1)
+		c.recordDrop(entry)
+	}
+}
+
+// With returns a child logger that adds fields to every entry it sends.
+func (l *Logger) With(fields ...Field) *Logger {
+	merged := make([]Field, 0, len(l.fields)+len(fields))
+	merged = append(merged, l.fields...)
+	merged = append(merged, fields...)
+	return &Logger{core: l.core, component: l.component, fields: merged, skip: l.skip}
+}
+
+// Named returns a child logger for a component. Its entries carry the
+// component name, and their minimum level can be overridden with
+// SetComponentLevels.
+func (l *Logger) Named(component string) *Logger {
+	return &Logger{core: l.core, component: component, fields: l.fields, skip: l.skip}
+}
+
+// Log sends an entry with the given level, message and fields. Entries below
+// the minimum level return before anything is allocated.
+func (l *Logger) Log(level Level, msg string, fields ...Field) {
+	l.log(level, msg, fields)
+}
+
+// LogEntry sends an entry that was built elsewhere, such as one read back by
+// the logfile package, keeping its time, component, caller and stack. The
+// fields of the logger come before those of the entry, and the component of
+// the logger is used if the entry has none. e is not modified.
+func (l *Logger) LogEntry(e *Entry) {
+	component := e.Component
+	if component == "" {
+		component = l.component
+	}
+	if !l.core.levels.enabled(e.Level, component) {
+		return
+	}
+	entry := &Entry{
+		Time:      e.Time,
+		Level:     e.Level,
+		Component: component,
+		Message:   e.Message,
+		Caller:    e.Caller,
+		Stack:     e.Stack,
+		Span:      e.Span,
+	}
+	if n := len(l.fields) + len(e.Fields); n > 0 {
+		entry.Fields = make([]Field, 0, n)
+		entry.Fields = append(entry.Fields, l.fields...)
+		entry.Fields = append(entry.Fields, e.Fields...)
+	}
+	l.core.send(entry)
+}
+
+// log is called directly by every logging method, so that the code calling
+// the method is always the same number of frames up from send.
+func (l *Logger) log(level Level, msg string, fields []Field) {
+	if !l.core.levels.enabled(level, l.component) {
+		return
+	}
+	l.send(&Entry{Time: time.Now(), Level: level, Message: msg}, fields, 0)
+}
+
+// send completes an entry for an enabled level, which has its time, level
+// and message set, and hands it to the core. pc is the caller if it is
+// already known, or 0.
+func (l *Logger) send(entry *Entry, fields []Field, pc uintptr) {
+	entry.Component = l.component
+	if n := len(l.fields) + len(fields); n > len(entry.fields) {
+		entry.Fields = make([]Field, 0, n)
+	} else if n > 0 {
+		entry.Fields = entry.fields[:0]
+	}
+	entry.Fields = append(entry.Fields, l.fields...)
+	entry.Fields = append(entry.Fields, fields...)
+	l.addCaller(entry, pc)
+	l.core.send(entry)
+}
+
+func (l *Logger) Trace(msg string, fields ...Field) {
+	l.log(LevelTrace, msg, fields)
+}
+
+func (l *Logger) Debug(msg string, fields ...Field) {
+	l.log(LevelDebug, msg, fields)
+}
+
+func (l *Logger) Info(msg string, fields ...Field) {
+	l.log(LevelInfo, msg, fields)
+}
+
+func (l *Logger) Warning(msg string, fields ...Field) {
+	l.log(LevelWarning, msg, fields)
+}
+
+func (l *Logger) Error(msg string, fields ...Field) {
+	l.log(LevelError, msg, fields)
+}
+
+// Fatal sends an entry at LevelFatal, waits until every buffered entry has
+// been written and exits the process with status 1.
+func (l *Logger) Fatal(msg string, fields ...Field) {
+	l.log(LevelFatal, msg, fields)
+	l.Close()
+	os.Exit(1)
+}
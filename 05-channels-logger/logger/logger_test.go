@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// benchmarkHotPath logs through a logger at the default level, LevelInfo, so
+// Debug is disabled. Its sink takes every level and discards the output, so
+// only the cost of the logger itself is measured. Run with -benchmem: a
+// disabled level should not allocate, and an enabled one only the entry.
+func benchmarkHotPath(b *testing.B, log func(l *Logger, i int)) {
+	l := New(WithSink("discard", NewWriterSink(io.Discard, &JSONEncoder{}), LevelTrace))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		log(l, i)
+	}
+	l.Close()
+}
+
+func BenchmarkDisabledDebug(b *testing.B) {
+	benchmarkHotPath(b, func(l *Logger, i int) {
+		l.Debug("Cache miss", Int("attempt", i), String("key", "user:42"))
+	})
+}
+
+func BenchmarkInfo(b *testing.B) {
+	benchmarkHotPath(b, func(l *Logger, i int) {
+		l.Info("App is running")
+	})
+}
+
+func BenchmarkInfoFields(b *testing.B) {
+	benchmarkHotPath(b, func(l *Logger, i int) {
+		l.Info("Request served", Int("status", 200), Duration("took", time.Millisecond),
+			String("path", "/users"), Float64("ratio", 0.5))
+	})
+}
+
+func BenchmarkInfoWith(b *testing.B) {
+	benchmarkHotPath(b, func(l *Logger, i int) {
+		l.Named("db").Info("Query", Int64("rows", int64(i)))
+	})
+}
@@ -0,0 +1,317 @@
+package logstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+)
+
+// A record is an entry framed by its length and checksum:
+//
+//	uint32 length of the payload, little endian
+//	uint32 CRC-32C of the payload
+//	payload: the entry, see appendEntry
+const recordHeader = 8
+
+// maxRecord is the largest payload a record may have. Anything longer is
+// taken for a corrupt length.
+const maxRecord = 16 << 20
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	errCorrupt  = errors.New("logstore: corrupt record")
+	errTooLarge = errors.New("logstore: entry too large for a record")
+
+	// errTorn is returned by readRecords for a record cut short by the end
+	// of the data, as a crash leaves at the end of the segment being written.
+	errTorn = errors.New("logstore: torn record")
+)
+
+// appendRecord appends e to dst as a framed record.
+func appendRecord(dst []byte, e *logger.Entry) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, 0, 0, 0, 0, 0, 0, 0, 0)
+	dst = appendEntry(dst, e)
+	payload := dst[start+recordHeader:]
+	if len(payload) > maxRecord {
+		return dst[:start], errTooLarge
+	}
+	binary.LittleEndian.PutUint32(dst[start:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(dst[start+4:], crc32.Checksum(payload, crcTable))
+	return dst, nil
+}
+
+// readRecords calls fn with the offset and payload of every record in r,
+// which starts at offset. The payload is only valid during the call. It
+// stops at the end of r, at a torn record, for which it returns errTorn, or
+// at a corrupt record, for which it returns an error wrapping errCorrupt. It
+// returns the offset just past the last valid record.
+func readRecords(r io.Reader, offset int64, fn func(offset int64, payload []byte) error) (int64, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	var header [recordHeader]byte
+	var payload []byte
+	for {
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF {
+				return offset, nil
+			}
+			if err == io.ErrUnexpectedEOF {
+				return offset, errTorn
+			}
+			return offset, err
+		}
+		n := binary.LittleEndian.Uint32(header[:])
+		if n > maxRecord {
+			return offset, corruptAt(offset) // appendRecord never writes such a length
+		}
+		if cap(payload) < int(n) {
+			payload = make([]byte, n)
+		}
+		payload = payload[:n]
+		if m, err := io.ReadFull(br, payload); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return offset, tornOrCorrupt(payload[:m], offset)
+			}
+			return offset, err
+		}
+		if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:]) {
+			return offset, corruptAt(offset)
+		}
+		if err := fn(offset, payload); err != nil {
+			return offset, err
+		}
+		offset += recordHeader + int64(n)
+	}
+}
+
+// tornOrCorrupt tells a record whose payload runs past the end of the data
+// because a crash cut it short, which is torn, from one whose length was
+// damaged, which is corrupt: valid records then follow its header. rest is
+// everything after the header.
+func tornOrCorrupt(rest []byte, offset int64) error {
+	for i := 0; i+recordHeader <= len(rest); i++ {
+		n := int(binary.LittleEndian.Uint32(rest[i:]))
+		if n == 0 || n > len(rest)-i-recordHeader {
+			continue
+		}
+		payload := rest[i+recordHeader : i+recordHeader+n]
+		if crc32.Checksum(payload, crcTable) == binary.LittleEndian.Uint32(rest[i+4:]) {
+			return corruptAt(offset)
+		}
+	}
+	return errTorn
+}
+
+func corruptAt(offset int64) error {
+	return fmt.Errorf("%w at offset %d", errCorrupt, offset)
+}
+
+// The payload starts with the time and level, so the index and the filters
+// of Scan can read them without decoding the rest:
+//
+//	varint   time in Unix nanoseconds
+//	byte     level
+//	string   component, message, caller file
+//	varint   caller line
+//	string   caller function, stack
+//	byte     span phase
+//	uvarint  span ID, span goroutine
+//	uvarint  number of fields, then for each: string key, byte kind, value
+//
+// Strings are a uvarint length followed by the bytes.
+func appendEntry(dst []byte, e *logger.Entry) []byte {
+	dst = binary.AppendVarint(dst, e.Time.UnixNano())
+	dst = append(dst, byte(e.Level))
+	dst = appendString(dst, e.Component)
+	dst = appendString(dst, e.Message)
+	dst = appendString(dst, e.Caller.File)
+	dst = binary.AppendVarint(dst, int64(e.Caller.Line))
+	dst = appendString(dst, e.Caller.Function)
+	dst = appendString(dst, e.Stack)
+	dst = append(dst, byte(e.Span.Phase))
+	dst = binary.AppendUvarint(dst, e.Span.ID)
+	dst = binary.AppendUvarint(dst, e.Span.Goroutine)
+	dst = binary.AppendUvarint(dst, uint64(len(e.Fields)))
+	for _, f := range e.Fields {
+		dst = appendField(dst, f)
+	}
+	return dst
+}
+
+// appendField stores the typed kinds as they are. Errors and Stringers are
+// stored as their text, and other values as JSON, since only the text can
+// be read back.
+func appendField(dst []byte, f logger.Field) []byte {
+	dst = appendString(dst, f.Key)
+	v := f.Value()
+	switch f.Kind {
+	case logger.KindString:
+		return appendString(append(dst, byte(f.Kind)), v.(string))
+	case logger.KindInt64:
+		return binary.AppendVarint(append(dst, byte(f.Kind)), v.(int64))
+	case logger.KindUint64:
+		return binary.AppendUvarint(append(dst, byte(f.Kind)), v.(uint64))
+	case logger.KindFloat64:
+		return binary.LittleEndian.AppendUint64(append(dst, byte(f.Kind)), math.Float64bits(v.(float64)))
+	case logger.KindBool:
+		if v.(bool) {
+			return append(dst, byte(f.Kind), 1)
+		}
+		return append(dst, byte(f.Kind), 0)
+	case logger.KindTime:
+		return binary.AppendVarint(append(dst, byte(f.Kind)), v.(time.Time).UnixNano())
+	case logger.KindDuration:
+		return binary.AppendVarint(append(dst, byte(f.Kind)), int64(v.(time.Duration)))
+	case logger.KindError:
+		if err, ok := v.(error); ok {
+			return appendString(append(dst, byte(f.Kind)), err.Error())
+		}
+	case logger.KindAny:
+		if b, err := json.Marshal(v); err == nil {
+			return appendString(append(dst, byte(f.Kind)), string(b))
+		}
+	}
+	return appendString(append(dst, byte(logger.KindString)), fmt.Sprint(v))
+}
+
+func appendString(dst []byte, s string) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// decoder reads a payload. The first error sticks, so the fields can be read
+// one after the other and the error checked once at the end.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) fail() {
+	d.err = errCorrupt
+	d.b = nil
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if len(d.b) == 0 {
+		d.fail()
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.fail()
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
+
+// peekEntry reads only the time and level of a payload.
+func peekEntry(payload []byte) (int64, logger.Level, error) {
+	d := decoder{b: payload}
+	t := d.varint()
+	level := logger.Level(d.byte())
+	return t, level, d.err
+}
+
+func decodeEntry(payload []byte) (*logger.Entry, error) {
+	d := decoder{b: payload}
+	e := &logger.Entry{
+		Time:      time.Unix(0, d.varint()),
+		Level:     logger.Level(d.byte()),
+		Component: d.string(),
+		Message:   d.string(),
+	}
+	e.Caller.File = d.string()
+	e.Caller.Line = int(d.varint())
+	e.Caller.Function = d.string()
+	e.Stack = d.string()
+	e.Span.Phase = logger.SpanPhase(d.byte())
+	e.Span.ID = d.uvarint()
+	e.Span.Goroutine = d.uvarint()
+	n := d.uvarint()
+	if n > uint64(len(d.b)) { // Every field takes at least two bytes
+		d.fail()
+	}
+	if n > 0 && d.err == nil {
+		e.Fields = make([]logger.Field, 0, n)
+	}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		e.Fields = append(e.Fields, d.field())
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return e, nil
+}
+
+func (d *decoder) field() logger.Field {
+	key := d.string()
+	switch kind := logger.FieldKind(d.byte()); kind {
+	case logger.KindString:
+		return logger.String(key, d.string())
+	case logger.KindInt64:
+		return logger.Int64(key, d.varint())
+	case logger.KindUint64:
+		return logger.Uint64(key, d.uvarint())
+	case logger.KindFloat64:
+		if len(d.b) < 8 {
+			d.fail()
+			return logger.Field{}
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.b))
+		d.b = d.b[8:]
+		return logger.Float64(key, v)
+	case logger.KindBool:
+		return logger.Bool(key, d.byte() != 0)
+	case logger.KindTime:
+		return logger.Time(key, time.Unix(0, d.varint()))
+	case logger.KindDuration:
+		return logger.Duration(key, time.Duration(d.varint()))
+	case logger.KindError:
+		return logger.Any(key, errors.New(d.string()))
+	case logger.KindAny:
+		var v any
+		if err := json.Unmarshal([]byte(d.string()), &v); err != nil {
+			d.fail()
+		}
+		return logger.Any(key, v)
+	}
+	d.fail()
+	return logger.Field{}
+}
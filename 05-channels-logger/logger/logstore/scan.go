@@ -0,0 +1,153 @@
+package logstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+)
+
+// Query selects the entries passed to the function of Scan.
+type Query struct {
+	Since, Until time.Time    // Both included, the zero time leaves the range open
+	MinLevel     logger.Level // Like everywhere else, the zero value is LevelInfo
+}
+
+func (q Query) bounds() (since, until int64) {
+	since, until = math.MinInt64, math.MaxInt64
+	if !q.Since.IsZero() {
+		since = q.Since.UnixNano()
+	}
+	if !q.Until.IsZero() {
+		until = q.Until.UnixNano()
+	}
+	return since, until
+}
+
+// match tells whether a block of the index can hold entries for q.
+func (q Query) match(b *block) bool {
+	since, until := q.bounds()
+	return b.records > 0 && b.overlaps(since, until) && b.levels.atLeast(q.MinLevel)
+}
+
+// Scan calls fn for every entry of the store in dir that matches q, in the
+// order they were written, until fn returns an error. Only the blocks whose
+// index says they may hold such entries are read. Segments that are still
+// being written have no index yet, so they are read whole.
+func Scan(dir string, q Query, fn func(e *logger.Entry) error) error {
+	segs, err := listSegments(dir)
+	if err != nil {
+		return err
+	}
+	for _, seg := range segs {
+		ix, _, err := loadIndex(seg, defaultIndexBytes)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // Deleted by the Sink since it was listed
+		}
+		if err != nil {
+			return err
+		}
+		total := ix.total()
+		if !q.match(&total) {
+			continue
+		}
+		if err := scanSegment(seg, ix, q, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanSegment(seg segment, ix *index, q Query, fn func(e *logger.Entry) error) error {
+	f, err := os.Open(seg.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	since, until := q.bounds()
+	filter := func(_ int64, payload []byte) error {
+		t, level, err := peekEntry(payload)
+		if err != nil {
+			return err
+		}
+		if t < since || t > until || level < q.MinLevel {
+			return nil
+		}
+		e, err := decodeEntry(payload)
+		if err != nil {
+			return err
+		}
+		return fn(e)
+	}
+	for i := 0; i < len(ix.blocks); i++ {
+		if !q.match(&ix.blocks[i]) {
+			continue
+		}
+		start := ix.blocks[i].offset
+		for i+1 < len(ix.blocks) && q.match(&ix.blocks[i+1]) {
+			i++ // Reads consecutive matching blocks in one go
+		}
+		end := ix.end(i)
+		if _, err := readRecords(io.NewSectionReader(f, start, end-start), start, filter); err != nil {
+			if err == errTorn { // The index says the block ends further on
+				err = errCorrupt
+			}
+			if errors.Is(err, errCorrupt) {
+				err = fmt.Errorf("reading %s: %w", seg.path, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// SegmentInfo describes a segment of a store.
+type SegmentInfo struct {
+	Path        string
+	Size        int64 // Bytes of valid records, a torn record at the end is not counted
+	Entries     int
+	Blocks      int            // Points of the sparse index
+	First, Last time.Time      // Times of the oldest and newest entries
+	Levels      []logger.Level // Levels that have entries in the segment
+	Indexed     bool           // False for the segment being written or one left by a crash
+}
+
+// Segments describes the segments of the store in dir, oldest first.
+func Segments(dir string) ([]SegmentInfo, error) {
+	segs, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SegmentInfo, 0, len(segs))
+	for _, seg := range segs {
+		ix, indexed, err := loadIndex(seg, defaultIndexBytes)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		total := ix.total()
+		info := SegmentInfo{
+			Path:    seg.path,
+			Size:    ix.size,
+			Entries: int(total.records),
+			Blocks:  len(ix.blocks),
+			Levels:  total.levels.levels(),
+			Indexed: indexed,
+		}
+		if total.records > 0 {
+			info.First, info.Last = time.Unix(0, total.min), time.Unix(0, total.max)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
@@ -0,0 +1,129 @@
+package logstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+)
+
+// writeStore writes n entries across segments of small blocks, so Scan has
+// several segments and blocks to skip.
+func writeStore(t *testing.T, dir string, n int) {
+	t.Helper()
+	s, err := NewSink(Config{Dir: dir, SegmentBytes: 4 << 10, IndexBytes: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := s.WriteEntry(testEntry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanFiltersByTimeAndLevel(t *testing.T) {
+	dir := t.TempDir()
+	writeStore(t, dir, 300)
+	segs, err := Segments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) < 2 || segs[0].Blocks < 2 {
+		t.Fatalf("store has %d segments, the first with %d blocks", len(segs), segs[0].Blocks)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  func(i int) bool // Which of the entries should match
+	}{
+		{"everything", Query{MinLevel: logger.LevelTrace}, func(i int) bool { return true }},
+		{"errors", Query{MinLevel: logger.LevelError}, func(i int) bool { return i%3 == 2 }},
+		{"range", Query{
+			Since:    testStart.Add(100 * time.Second),
+			Until:    testStart.Add(199 * time.Second),
+			MinLevel: logger.LevelInfo,
+		}, func(i int) bool { return i >= 100 && i <= 199 }},
+		{"warnings in range", Query{
+			Since:    testStart.Add(250 * time.Second),
+			MinLevel: logger.LevelWarning,
+		}, func(i int) bool { return i >= 250 && i%3 != 0 }},
+		{"nothing", Query{Since: testStart.Add(time.Hour)}, func(i int) bool { return false }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, err := scanAll(t, dir, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for i := 0; i < 300; i++ {
+				if tt.want(i) {
+					want = append(want, fmt.Sprintf("entry %d", i))
+				}
+			}
+			if len(msgs) != len(want) {
+				t.Fatalf("got %d entries, want %d", len(msgs), len(want))
+			}
+			for i := range want {
+				if msgs[i] != want[i] {
+					t.Fatalf("entry %d is %q, want %q", i, msgs[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScanDecodesEntries(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSink(Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &logger.Entry{
+		Time:      testStart,
+		Level:     logger.LevelError,
+		Component: "db",
+		Message:   "query failed",
+		Caller:    logger.Caller{File: "db.go", Line: 42, Function: "db.Query"},
+		Stack:     "goroutine 1 [running]:",
+		Fields: []logger.Field{
+			logger.String("table", "users"),
+			logger.Int("rows", -1),
+			logger.Float64("took", 1.5),
+			logger.Bool("retry", true),
+			logger.Duration("timeout", time.Second),
+		},
+	}
+	s.WriteEntry(in)
+	s.Close()
+
+	var out *logger.Entry
+	err = Scan(dir, Query{}, func(e *logger.Entry) error {
+		out = e
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == nil {
+		t.Fatal("Scan found no entry")
+	}
+	if !out.Time.Equal(in.Time) || out.Level != in.Level || out.Component != in.Component ||
+		out.Message != in.Message || out.Caller != in.Caller || out.Stack != in.Stack {
+		t.Fatalf("got %+v, want %+v", out, in)
+	}
+	if len(out.Fields) != len(in.Fields) {
+		t.Fatalf("got %d fields, want %d", len(out.Fields), len(in.Fields))
+	}
+	for i, f := range in.Fields {
+		if out.Fields[i].Key != f.Key || out.Fields[i].Value() != f.Value() {
+			t.Errorf("field %d is %s=%v, want %s=%v", i, out.Fields[i].Key, out.Fields[i].Value(), f.Key, f.Value())
+		}
+	}
+}
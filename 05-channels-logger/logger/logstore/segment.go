@@ -0,0 +1,295 @@
+package logstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+)
+
+// A segment is a file named after its sequence number, like
+// 00000000000000000042.seg, that starts with segmentMagic followed by
+// records. Once the sink moves on to the next segment, it writes the sparse
+// index of the segment next to it, in 00000000000000000042.idx.
+const (
+	segmentMagic = "logseg1\n"
+	indexMagic   = "logidx1\n"
+	segmentExt   = ".seg"
+	indexExt     = ".idx"
+)
+
+// levelSet has a bit for each level.
+type levelSet uint8
+
+func levelBit(l logger.Level) levelSet {
+	i := min(max(int(l-logger.LevelTrace), 0), int(logger.LevelFatal-logger.LevelTrace))
+	return 1 << i
+}
+
+// atLeast tells whether the set has level min or any above it.
+func (s levelSet) atLeast(min logger.Level) bool {
+	return s&^(levelBit(min)-1) != 0
+}
+
+// levels returns the levels in the set, lowest first.
+func (s levelSet) levels() []logger.Level {
+	var levels []logger.Level
+	for l := logger.LevelTrace; l <= logger.LevelFatal; l++ {
+		if s&levelBit(l) != 0 {
+			levels = append(levels, l)
+		}
+	}
+	return levels
+}
+
+// block is a run of consecutive records described by one point of the
+// sparse index. It runs until the offset of the next block, or the end of
+// the segment.
+type block struct {
+	offset   int64
+	min, max int64 // Unix nanoseconds, entries are not always in time order
+	levels   levelSet
+	records  uint32
+}
+
+func (b *block) overlaps(since, until int64) bool {
+	return b.max >= since && b.min <= until
+}
+
+// blockSize is the size of a block in the index file.
+const blockSize = 8 + 8 + 8 + 1 + 4
+
+// index is the sparse index of a segment.
+type index struct {
+	size   int64 // Bytes of the segment it covers
+	blocks []block
+}
+
+// add records a record of n bytes at offset. A new block starts once the
+// current one spans every bytes.
+func (ix *index) add(offset, n, t int64, level logger.Level, every int64) {
+	if len(ix.blocks) == 0 || offset-ix.blocks[len(ix.blocks)-1].offset >= every {
+		ix.blocks = append(ix.blocks, block{offset: offset, min: math.MaxInt64, max: math.MinInt64})
+	}
+	b := &ix.blocks[len(ix.blocks)-1]
+	b.min = min(b.min, t)
+	b.max = max(b.max, t)
+	b.levels |= levelBit(level)
+	b.records++
+	ix.size = offset + n
+}
+
+// total returns a block covering the whole segment.
+func (ix *index) total() block {
+	t := block{offset: int64(len(segmentMagic)), min: math.MaxInt64, max: math.MinInt64}
+	for _, b := range ix.blocks {
+		t.min = min(t.min, b.min)
+		t.max = max(t.max, b.max)
+		t.levels |= b.levels
+		t.records += b.records
+	}
+	return t
+}
+
+// end returns the offset where block i ends.
+func (ix *index) end(i int) int64 {
+	if i+1 < len(ix.blocks) {
+		return ix.blocks[i+1].offset
+	}
+	return ix.size
+}
+
+// The index file holds indexMagic, the size of the segment it covers as a
+// uint64, the number of blocks as a uint32, the blocks, and a CRC-32C of
+// all of that. Integers are little endian.
+func (ix *index) marshal() []byte {
+	buf := make([]byte, 0, len(indexMagic)+12+len(ix.blocks)*blockSize+4)
+	buf = append(buf, indexMagic...)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(ix.size))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(ix.blocks)))
+	for _, b := range ix.blocks {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(b.offset))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(b.min))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(b.max))
+		buf = append(buf, byte(b.levels))
+		buf = binary.LittleEndian.AppendUint32(buf, b.records)
+	}
+	return binary.LittleEndian.AppendUint32(buf, crc32.Checksum(buf, crcTable))
+}
+
+var errBadIndex = errors.New("logstore: invalid index")
+
+func unmarshalIndex(buf []byte) (*index, error) {
+	header := len(indexMagic) + 12
+	if len(buf) < header+4 || string(buf[:len(indexMagic)]) != indexMagic {
+		return nil, errBadIndex
+	}
+	body, sum := buf[:len(buf)-4], binary.LittleEndian.Uint32(buf[len(buf)-4:])
+	if crc32.Checksum(body, crcTable) != sum {
+		return nil, errBadIndex
+	}
+	ix := &index{size: int64(binary.LittleEndian.Uint64(body[len(indexMagic):]))}
+	n := int(binary.LittleEndian.Uint32(body[len(indexMagic)+8:]))
+	body = body[header:]
+	if len(body) != n*blockSize {
+		return nil, errBadIndex
+	}
+	ix.blocks = make([]block, n)
+	for i := range ix.blocks {
+		b := body[i*blockSize:]
+		ix.blocks[i] = block{
+			offset:  int64(binary.LittleEndian.Uint64(b)),
+			min:     int64(binary.LittleEndian.Uint64(b[8:])),
+			max:     int64(binary.LittleEndian.Uint64(b[16:])),
+			levels:  levelSet(b[24]),
+			records: binary.LittleEndian.Uint32(b[25:]),
+		}
+	}
+	return ix, nil
+}
+
+// writeIndex writes the index file of a segment under a temporary name
+// first, so a crash never leaves half an index behind.
+func writeIndex(path string, ix *index) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(ix.marshal())
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing index %s: %w", path, err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes the creation and renaming of files in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// segment is a segment file found in a store directory.
+type segment struct {
+	seq  uint64
+	path string
+}
+
+func (s segment) indexPath() string {
+	return strings.TrimSuffix(s.path, segmentExt) + indexExt
+}
+
+func segmentName(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+// listSegments returns the segments in dir, oldest first.
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []segment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue // Not one of ours
+		}
+		segs = append(segs, segment{seq, filepath.Join(dir, name)})
+	}
+	sort.Slice(segs, func(i, j int) bool {
+		return segs[i].seq < segs[j].seq
+	})
+	return segs, nil
+}
+
+// readIndex returns the index file of a segment of size bytes, or nil if it
+// is missing, invalid, or does not cover the whole segment, as for the one
+// still being written.
+func readIndex(seg segment, size int64) *index {
+	buf, err := os.ReadFile(seg.indexPath())
+	if err != nil {
+		return nil
+	}
+	ix, err := unmarshalIndex(buf)
+	if err != nil || ix.size != size {
+		return nil
+	}
+	return ix
+}
+
+// loadIndex returns the index of a segment, and whether it was read from
+// its index file. Otherwise the index is rebuilt by reading the records, up
+// to a torn record at the end. The segment is never modified.
+func loadIndex(seg segment, every int64) (*index, bool, error) {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	if ix := readIndex(seg, info.Size()); ix != nil {
+		return ix, true, nil
+	}
+	ix, err := rebuildIndex(f, every)
+	return ix, false, err
+}
+
+func rebuildIndex(f *os.File, every int64) (*index, error) {
+	magic := make([]byte, len(segmentMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return &index{}, nil // Torn before the first record
+		}
+		return nil, err
+	}
+	if string(magic) != segmentMagic {
+		return nil, fmt.Errorf("logstore: %s is not a segment", f.Name())
+	}
+	ix := &index{size: int64(len(segmentMagic))}
+	_, err := readRecords(f, ix.size, func(offset int64, payload []byte) error {
+		t, level, err := peekEntry(payload)
+		if err != nil {
+			return err
+		}
+		ix.add(offset, recordHeader+int64(len(payload)), t, level, every)
+		return nil
+	})
+	if err != nil && err != errTorn {
+		return nil, fmt.Errorf("reading %s: %w", f.Name(), err)
+	}
+	return ix, nil
+}
@@ -0,0 +1,270 @@
+// Package logstore keeps entries in an append-only store that can be
+// queried by time range without reading it whole. A store is a directory of
+// segment files, each with a sparse index that gives the time range and
+// levels of every block of about 64 KiB, so Scan reads only the blocks that
+// can hold matching entries:
+//
+//	store, err := logstore.NewSink(logstore.Config{Dir: "/var/log/app/store", MaxSegments: 100})
+//	log := logger.New(logger.WithSink("store", store, logger.LevelDebug))
+//	...
+//	err = logstore.Scan("/var/log/app/store", logstore.Query{Since: t, Until: t.Add(5 * time.Minute), MinLevel: logger.LevelWarning},
+//		func(e *logger.Entry) error { ... })
+//
+// Every record carries a checksum. A crash can only leave a torn record at
+// the end of the segment being written; readers stop before it, and the next
+// Sink opened on the directory truncates it and writes the missing index. A
+// record is only taken for torn when it runs past the end of the file with
+// no valid record after its header. Any other bad length or checksum is
+// corruption rather than a crash, and Scan and NewSink return an error for
+// it instead of dropping the rest of the segment.
+package logstore
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+)
+
+const (
+	defaultSegmentBytes = 64 << 20
+	defaultIndexBytes   = 64 << 10
+
+	// flushLatency is how long entries wait in the buffer of a Sink.
+	flushLatency = 100 * time.Millisecond
+)
+
+// Config configures a Sink. Only Dir is required.
+type Config struct {
+	Dir          string
+	SegmentBytes int64         // Starts a new segment once this size is reached, defaults to 64 MiB
+	IndexBytes   int64         // Size of the blocks of the sparse index, defaults to 64 KiB
+	MaxSegments  int           // Segments to keep, 0 keeps all of them
+	MaxAge       time.Duration // Deletes segments whose newest entry is older than this, 0 keeps all of them
+
+	// Fsync syncs the segment to disk at every flush, instead of only when
+	// it is complete. Without it, a crash of the machine, not just of the
+	// process, loses the entries of the last few seconds.
+	Fsync bool
+}
+
+// Sink writes entries to the segments of a store directory. Only one Sink
+// may write to a directory at a time, but any number of readers may Scan it
+// meanwhile.
+type Sink struct {
+	cfg   Config
+	seg   segment
+	file  *os.File // Nil after a failed write, the next one starts a new segment
+	w     *bufio.Writer
+	index index
+	buf   []byte
+}
+
+// NewSink recovers the segments left in cfg.Dir, creating it if needed, and
+// starts a new segment. Recovery truncates torn records at the end of
+// segments and writes the index of those that lack one. A segment corrupt
+// before its end is left as it is, and NewSink fails.
+func NewSink(cfg Config) (*Sink, error) {
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = defaultSegmentBytes
+	}
+	if cfg.IndexBytes <= 0 {
+		cfg.IndexBytes = defaultIndexBytes
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	segs, err := listSegments(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, seg := range segs {
+		if err := recoverSegment(seg, cfg.IndexBytes); err != nil {
+			return nil, err
+		}
+	}
+	s := &Sink{cfg: cfg}
+	if len(segs) > 0 {
+		s.seg.seq = segs[len(segs)-1].seq
+	}
+	if err := s.create(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// recoverSegment truncates a torn record at the end of a segment and writes
+// its index, unless it already has a valid one. Empty segments are removed.
+func recoverSegment(seg segment, every int64) error {
+	f, err := os.OpenFile(seg.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if readIndex(seg, info.Size()) != nil {
+		return nil
+	}
+	ix, err := rebuildIndex(f, every)
+	if err != nil {
+		return err
+	}
+	if len(ix.blocks) == 0 {
+		os.Remove(seg.indexPath())
+		return os.Remove(seg.path)
+	}
+	if ix.size < info.Size() {
+		if err := f.Truncate(ix.size); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return writeIndex(seg.indexPath(), ix)
+}
+
+// create starts the segment after the current one.
+func (s *Sink) create() error {
+	seq := s.seg.seq + 1
+	path := segmentName(s.cfg.Dir, seq)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(segmentMagic); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	s.seg = segment{seq, path}
+	s.file = f
+	if s.w == nil {
+		s.w = bufio.NewWriterSize(f, 64<<10)
+	} else {
+		s.w.Reset(f)
+	}
+	s.index = index{size: int64(len(segmentMagic))}
+	s.prune()
+	return syncDir(s.cfg.Dir)
+}
+
+func (s *Sink) WriteEntry(e *logger.Entry) error {
+	if s.file == nil {
+		if err := s.create(); err != nil {
+			return err
+		}
+	}
+	if s.index.size >= s.cfg.SegmentBytes {
+		err := s.seal()
+		if err == nil {
+			err = s.create()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	var err error
+	if s.buf, err = appendRecord(s.buf[:0], e); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(s.buf); err != nil {
+		s.abandon()
+		return err
+	}
+	s.index.add(s.index.size, int64(len(s.buf)), e.Time.UnixNano(), e.Level, s.cfg.IndexBytes)
+	if cap(s.buf) > 64<<10 {
+		s.buf = nil // Do not hold on to the buffer of a huge entry
+	}
+	return nil
+}
+
+// abandon gives up on the current segment after a failed write. It is left
+// without an index, as after a crash, and recovered by the next NewSink.
+func (s *Sink) abandon() {
+	s.file.Close()
+	s.file = nil
+}
+
+// seal completes the current segment: it is synced to disk and its index is
+// written next to it.
+func (s *Sink) seal() error {
+	f := s.file
+	s.file = nil
+	err := s.w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if len(s.index.blocks) == 0 {
+		return os.Remove(s.seg.path)
+	}
+	return writeIndex(s.seg.indexPath(), &s.index)
+}
+
+func (s *Sink) Flush() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.w.Flush()
+	if err != nil {
+		s.abandon()
+		return err
+	}
+	if s.cfg.Fsync {
+		err = s.file.Sync()
+	}
+	return err
+}
+
+func (s *Sink) MaxLatency() time.Duration {
+	return flushLatency
+}
+
+// Close seals the current segment.
+func (s *Sink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.seal()
+}
+
+// prune deletes the oldest segments according to MaxSegments and MaxAge.
+// The current segment is never deleted.
+func (s *Sink) prune() {
+	if s.cfg.MaxSegments <= 0 && s.cfg.MaxAge <= 0 {
+		return
+	}
+	segs, err := listSegments(s.cfg.Dir)
+	if err != nil {
+		return
+	}
+	for i, seg := range segs {
+		if seg.seq >= s.seg.seq {
+			break
+		}
+		drop := s.cfg.MaxSegments > 0 && len(segs)-i > s.cfg.MaxSegments
+		if !drop && s.cfg.MaxAge > 0 {
+			ix, _, err := loadIndex(seg, s.cfg.IndexBytes)
+			if err == nil {
+				drop = time.Since(time.Unix(0, ix.total().max)) > s.cfg.MaxAge
+			}
+		}
+		if drop {
+			if err := os.Remove(seg.path); err == nil || errors.Is(err, fs.ErrNotExist) {
+				os.Remove(seg.indexPath())
+			}
+		}
+	}
+}
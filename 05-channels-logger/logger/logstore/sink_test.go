@@ -0,0 +1,228 @@
+package logstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+)
+
+var testStart = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// testEntry is entry i of a test store: one a second, with the level going
+// from INFO to ERROR and back.
+func testEntry(i int) *logger.Entry {
+	levels := []logger.Level{logger.LevelInfo, logger.LevelWarning, logger.LevelError}
+	return &logger.Entry{
+		Time:    testStart.Add(time.Duration(i) * time.Second),
+		Level:   levels[i%len(levels)],
+		Message: fmt.Sprintf("entry %d", i),
+		Fields:  []logger.Field{logger.Int("i", i)},
+	}
+}
+
+// writeCrashed writes n entries and stops the way a crash would: the
+// segment is on disk, but it was never sealed, so it has no index.
+func writeCrashed(t *testing.T, dir string, n int) segment {
+	t.Helper()
+	s, err := NewSink(Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := s.WriteEntry(testEntry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	s.abandon()
+	return s.seg
+}
+
+// scanAll returns the messages of the entries Scan passes for q.
+func scanAll(t *testing.T, dir string, q Query) ([]string, error) {
+	t.Helper()
+	var msgs []string
+	err := Scan(dir, q, func(e *logger.Entry) error {
+		msgs = append(msgs, e.Message)
+		return nil
+	})
+	return msgs, err
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestNewSinkTruncatesATornRecord(t *testing.T) {
+	dir := t.TempDir()
+	seg := writeCrashed(t, dir, 10)
+	valid := fileSize(t, seg.path)
+	rec, err := appendRecord(nil, testEntry(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(rec[:len(rec)/2]) // The crash happened in the middle of a write
+	f.Close()
+
+	// Readers stop before the torn record, without modifying the segment
+	msgs, err := scanAll(t, dir, Query{MinLevel: logger.LevelTrace})
+	if err != nil || len(msgs) != 10 {
+		t.Fatalf("Scan before recovery got %d entries, %v", len(msgs), err)
+	}
+
+	s, err := NewSink(Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if size := fileSize(t, seg.path); size != valid {
+		t.Fatalf("recovered segment has %d bytes, want %d", size, valid)
+	}
+	if readIndex(seg, valid) == nil {
+		t.Fatal("recovered segment has no index")
+	}
+	msgs, err = scanAll(t, dir, Query{MinLevel: logger.LevelTrace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 10 || msgs[0] != "entry 0" || msgs[9] != "entry 9" {
+		t.Fatalf("Scan after recovery got %q", msgs)
+	}
+}
+
+func TestNewSinkKeepsASegmentCorruptBeforeItsEnd(t *testing.T) {
+	dir := t.TempDir()
+	seg := writeCrashed(t, dir, 10)
+	size := fileSize(t, seg.path)
+
+	// Flip a byte in the payload of the second record
+	f, err := os.OpenFile(seg.path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header [recordHeader]byte
+	f.ReadAt(header[:], int64(len(segmentMagic)))
+	second := int64(len(segmentMagic)) + recordHeader + int64(binary.LittleEndian.Uint32(header[:]))
+	var b [1]byte
+	f.ReadAt(b[:], second+recordHeader+2)
+	b[0] ^= 0xff
+	f.WriteAt(b[:], second+recordHeader+2)
+	f.Close()
+
+	if _, err := NewSink(Config{Dir: dir}); !errors.Is(err, errCorrupt) {
+		t.Fatalf("NewSink returned %v, want a corrupt record", err)
+	}
+	if got := fileSize(t, seg.path); got != size {
+		t.Fatalf("corrupt segment was truncated from %d to %d bytes", size, got)
+	}
+	if _, err := scanAll(t, dir, Query{MinLevel: logger.LevelTrace}); !errors.Is(err, errCorrupt) {
+		t.Fatalf("Scan returned %v, want a corrupt record", err)
+	}
+}
+
+func TestReadRecordsTellsTornFromCorrupt(t *testing.T) {
+	var data []byte
+	for i := 0; i < 3; i++ {
+		var err error
+		if data, err = appendRecord(data, testEntry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last := len(data) - 1
+	count := func(data []byte) (int, error) {
+		n := 0
+		_, err := readRecords(bytes.NewReader(data), 0, func(int64, []byte) error {
+			n++
+			return nil
+		})
+		return n, err
+	}
+
+	if n, err := count(data); n != 3 || err != nil {
+		t.Errorf("valid records: got %d, %v", n, err)
+	}
+	if n, err := count(data[:last]); n != 2 || err != errTorn {
+		t.Errorf("short last record: got %d, %v", n, err)
+	}
+	bad := append([]byte(nil), data...)
+	bad[last] ^= 0xff
+	if n, err := count(bad); n != 2 || !errors.Is(err, errCorrupt) {
+		t.Errorf("bad checksum on the last record: got %d, %v", n, err)
+	}
+	bad = append([]byte(nil), data...)
+	bad[recordHeader] ^= 0xff
+	if n, err := count(bad); n != 0 || !errors.Is(err, errCorrupt) {
+		t.Errorf("bad checksum on the first record: got %d, %v", n, err)
+	}
+	bad = append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(bad, maxRecord+1)
+	if n, err := count(bad); n != 0 || !errors.Is(err, errCorrupt) {
+		t.Errorf("length over maxRecord on the first record: got %d, %v", n, err)
+	}
+	bad = append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(bad, uint32(len(data)))
+	if n, err := count(bad); n != 0 || !errors.Is(err, errCorrupt) {
+		t.Errorf("length past the end on the first record: got %d, %v", n, err)
+	}
+}
+
+// recordOffset returns the offset of record i of a segment.
+func recordOffset(t *testing.T, data []byte, i int) int {
+	t.Helper()
+	offset := len(segmentMagic)
+	for ; i > 0; i-- {
+		if offset+recordHeader > len(data) {
+			t.Fatalf("segment has fewer records than %d", i)
+		}
+		offset += recordHeader + int(binary.LittleEndian.Uint32(data[offset:]))
+	}
+	return offset
+}
+
+func TestNewSinkKeepsASegmentWithADamagedLength(t *testing.T) {
+	for _, damaged := range []int{0, 500, 998} {
+		t.Run(fmt.Sprint("record ", damaged), func(t *testing.T) {
+			dir := t.TempDir()
+			seg := writeCrashed(t, dir, 1000)
+			data, err := os.ReadFile(seg.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data[recordOffset(t, data, damaged)+2] ^= 0x10 // One bit of the length
+			if err := os.WriteFile(seg.path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := NewSink(Config{Dir: dir}); !errors.Is(err, errCorrupt) {
+				t.Fatalf("NewSink returned %v, want a corrupt record", err)
+			}
+			after, err := os.ReadFile(seg.path)
+			if err != nil {
+				t.Fatalf("segment is gone: %v", err)
+			}
+			if !bytes.Equal(after, data) {
+				t.Fatalf("segment was modified, %d bytes before and %d after", len(data), len(after))
+			}
+			if _, err := os.Stat(seg.indexPath()); err == nil {
+				t.Fatal("an index was written for the corrupt segment")
+			}
+		})
+	}
+}
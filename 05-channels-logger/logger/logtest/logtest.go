@@ -0,0 +1,234 @@
+// Package logtest captures the entries of a logger in unit tests, so they
+// can assert on what was logged instead of redirecting stdout:
+//
+//	func TestCharge(t *testing.T) {
+//		log, rec := logtest.New(t)
+//		charge(log, 42)
+//		rec.AssertLogged(logtest.Level(logger.LevelError), logtest.Message("declined"), logtest.Field("order", 42))
+//		rec.AssertNotLogged(logtest.Level(logger.LevelFatal))
+//	}
+//
+// If the test fails, every captured entry is written to t.Log.
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+)
+
+// syncTimeout is how long the assertions wait for the entries logged before
+// them to reach the Recorder.
+const syncTimeout = 5 * time.Second
+
+// Recorder is a sink that keeps every entry it gets.
+type Recorder struct {
+	t   testing.TB
+	log *logger.Logger
+
+	mtx     sync.Mutex
+	entries []*logger.Entry
+}
+
+// New creates a logger that records every entry, from LevelTrace up, and is
+// closed when the test ends. opts are applied after the defaults, so they
+// can raise the level or add stages such as WithRedaction.
+func New(t testing.TB, opts ...logger.Option) (*logger.Logger, *Recorder) {
+	rec := &Recorder{t: t}
+	defaults := []logger.Option{
+		logger.WithSink("logtest", rec, logger.LevelTrace),
+		logger.WithLevel(logger.LevelTrace),
+		logger.WithBackpressure(logger.Block()), // Never drop an entry a test may look for
+	}
+	rec.log = logger.New(append(defaults, opts...)...)
+	t.Cleanup(func() {
+		rec.log.Close()
+		if t.Failed() {
+			rec.dump()
+		}
+	})
+	return rec.log, rec
+}
+
+func (r *Recorder) WriteEntry(e *logger.Entry) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.entries = append(r.entries, e)
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	return nil
+}
+
+// Entries returns the entries recorded so far, oldest first, after waiting
+// for the ones already logged to reach the Recorder.
+func (r *Recorder) Entries() []*logger.Entry {
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+	if err := r.log.Sync(ctx); err != nil {
+		r.t.Helper()
+		r.t.Fatalf("logtest: entries did not reach the recorder: %v", err)
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]*logger.Entry(nil), r.entries...)
+}
+
+// Reset forgets the entries recorded so far.
+func (r *Recorder) Reset() {
+	r.Entries() // Entries logged before Reset must not show up after it
+	r.mtx.Lock()
+	r.entries = nil
+	r.mtx.Unlock()
+}
+
+// Find returns the entries that match every matcher.
+func (r *Recorder) Find(ms ...Matcher) []*logger.Entry {
+	var found []*logger.Entry
+	for _, e := range r.Entries() {
+		if matchAll(e, ms) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// AssertLogged fails the test unless an entry matches every matcher, and
+// returns the first one that does.
+func (r *Recorder) AssertLogged(ms ...Matcher) *logger.Entry {
+	r.t.Helper()
+	found := r.Find(ms...)
+	if len(found) == 0 {
+		r.t.Errorf("logtest: no entry with %s", describe(ms))
+		return nil
+	}
+	return found[0]
+}
+
+// AssertNotLogged fails the test if any entry matches every matcher.
+func (r *Recorder) AssertNotLogged(ms ...Matcher) {
+	r.t.Helper()
+	if found := r.Find(ms...); len(found) > 0 {
+		r.t.Errorf("logtest: unexpected entry with %s:\n%s", describe(ms), format(found))
+	}
+}
+
+// AssertCount fails the test unless exactly n entries match every matcher.
+func (r *Recorder) AssertCount(n int, ms ...Matcher) {
+	r.t.Helper()
+	if found := r.Find(ms...); len(found) != n {
+		r.t.Errorf("logtest: got %d entries with %s, want %d", len(found), describe(ms), n)
+	}
+}
+
+// dump writes the recorded entries to the test log.
+func (r *Recorder) dump() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if len(r.entries) == 0 {
+		r.t.Log("logtest: nothing was logged")
+		return
+	}
+	r.t.Logf("logtest: %d entries were logged:\n%s", len(r.entries), format(r.entries))
+}
+
+func format(entries []*logger.Entry) string {
+	enc := &logger.TextEncoder{Time: logger.TimeRFC3339Nano}
+	var buf []byte
+	for _, e := range entries {
+		buf = append(buf, '\t')
+		buf = enc.Encode(buf, e)
+	}
+	return strings.TrimSuffix(string(buf), "\n")
+}
+
+// Matcher selects entries for the Recorder methods.
+type Matcher struct {
+	desc  string
+	match func(e *logger.Entry) bool
+}
+
+func (m Matcher) String() string {
+	return m.desc
+}
+
+// Level matches the entries at exactly level.
+func Level(level logger.Level) Matcher {
+	return Matcher{"level=" + level.String(), func(e *logger.Entry) bool {
+		return e.Level == level
+	}}
+}
+
+// AtLeast matches the entries at level or above.
+func AtLeast(level logger.Level) Matcher {
+	return Matcher{"level>=" + level.String(), func(e *logger.Entry) bool {
+		return e.Level >= level
+	}}
+}
+
+// Message matches the entries whose message contains substr.
+func Message(substr string) Matcher {
+	return Matcher{fmt.Sprintf("message containing %q", substr), func(e *logger.Entry) bool {
+		return strings.Contains(e.Message, substr)
+	}}
+}
+
+// Component matches the entries of loggers created with Named(name).
+func Component(name string) Matcher {
+	return Matcher{"component=" + name, func(e *logger.Entry) bool {
+		return e.Component == name
+	}}
+}
+
+// Field matches the entries with a field key whose value prints the same as
+// value, so Field("n", 3) matches logger.Int64("n", 3) and errors match
+// their message.
+func Field(key string, value any) Matcher {
+	want := fmt.Sprint(value)
+	return Matcher{fmt.Sprintf("%s=%v", key, value), func(e *logger.Entry) bool {
+		for _, f := range e.Fields {
+			if f.Key == key && fmt.Sprint(f.Value()) == want {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// HasField matches the entries with a field key, whatever its value.
+func HasField(key string) Matcher {
+	return Matcher{"field " + key, func(e *logger.Entry) bool {
+		for _, f := range e.Fields {
+			if f.Key == key {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+func matchAll(e *logger.Entry, ms []Matcher) bool {
+	for _, m := range ms {
+		if !m.match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func describe(ms []Matcher) string {
+	if len(ms) == 0 {
+		return "any content"
+	}
+	descs := make([]string, len(ms))
+	for i, m := range ms {
+		descs[i] = m.desc
+	}
+	return strings.Join(descs, ", ")
+}
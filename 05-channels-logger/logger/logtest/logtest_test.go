@@ -0,0 +1,169 @@
+package logtest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dangarmol/go-notes/05-channels-logger/logger"
+)
+
+// fakeT records what the Recorder reports, so failing assertions can be
+// tested without failing the test. Embedding testing.TB provides the methods
+// the Recorder does not call.
+type fakeT struct {
+	testing.TB
+	errors   []string
+	logs     []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+}
+
+func (t *fakeT) Log(args ...any) {
+	t.logs = append(t.logs, fmt.Sprint(args...))
+}
+
+func (t *fakeT) Logf(format string, args ...any) {
+	t.logs = append(t.logs, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeT) Failed() bool {
+	return len(t.errors) > 0
+}
+
+// end runs the cleanups the way the testing package does when a test ends.
+func (t *fakeT) end() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestNewRecordsEveryLevel(t *testing.T) {
+	ft := &fakeT{}
+	log, rec := New(ft)
+	defer ft.end()
+	log.Trace("trace")
+	log.Debug("debug")
+	log.Named("db").Error("failed")
+
+	entries := rec.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("recorded %d entries, want 3", len(entries))
+	}
+	if entries[0].Level != logger.LevelTrace || entries[2].Component != "db" {
+		t.Errorf("recorded %+v", entries)
+	}
+	rec.Reset()
+	if n := len(rec.Entries()); n != 0 {
+		t.Errorf("recorded %d entries after Reset", n)
+	}
+	if len(ft.errors) != 0 {
+		t.Errorf("Recorder reported %q", ft.errors)
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	ft := &fakeT{}
+	log, rec := New(ft)
+	defer ft.end()
+	log.Named("payments").Error("card declined", logger.Int64("order", 42), logger.Err(errors.New("insufficient funds")))
+	log.Info("retrying", logger.String("order", "42"))
+
+	tests := []struct {
+		name   string
+		assert func()
+		fails  bool
+	}{
+		{"logged", func() { rec.AssertLogged(Level(logger.LevelError), Message("declined")) }, false},
+		{"logged with fields", func() {
+			rec.AssertLogged(Component("payments"), Field("order", 42), Field("error", "insufficient funds"))
+		}, false},
+		{"not logged", func() { rec.AssertLogged(Level(logger.LevelWarning)) }, true},
+		{"field with another value", func() { rec.AssertLogged(Field("order", 43)) }, true},
+		{"has field", func() { rec.AssertLogged(AtLeast(logger.LevelError), HasField("error")) }, false},
+		{"not logged passes", func() { rec.AssertNotLogged(AtLeast(logger.LevelFatal)) }, false},
+		{"not logged fails", func() { rec.AssertNotLogged(Message("retrying")) }, true},
+		{"count", func() { rec.AssertCount(2, Field("order", 42)) }, false},
+		{"wrong count", func() { rec.AssertCount(1, HasField("order")) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft.errors = nil
+			tt.assert()
+			if failed := len(ft.errors) > 0; failed != tt.fails {
+				t.Errorf("assertion failed: %v, want %v, reported %q", failed, tt.fails, ft.errors)
+			}
+		})
+	}
+}
+
+func TestFailureMessages(t *testing.T) {
+	ft := &fakeT{}
+	log, rec := New(ft)
+	defer ft.end()
+	log.Info("retrying", logger.Int("attempt", 2))
+
+	rec.AssertLogged(Level(logger.LevelError), Field("attempt", 3))
+	rec.AssertNotLogged(Message("retry"))
+	if len(ft.errors) != 2 {
+		t.Fatalf("Recorder reported %q, want 2 errors", ft.errors)
+	}
+	if want := "no entry with level=ERROR, attempt=3"; !strings.Contains(ft.errors[0], want) {
+		t.Errorf("AssertLogged reported %q, want it to contain %q", ft.errors[0], want)
+	}
+	if !strings.Contains(ft.errors[1], `message containing "retry"`) || !strings.Contains(ft.errors[1], "attempt=2") {
+		t.Errorf("AssertNotLogged reported %q, want the matchers and the entry", ft.errors[1])
+	}
+}
+
+func TestEntriesAreDumpedOnFailure(t *testing.T) {
+	ft := &fakeT{}
+	log, _ := New(ft)
+	log.Info("first", logger.Int("n", 1))
+	log.Warning("second")
+	ft.end()
+	if len(ft.logs) != 0 {
+		t.Fatalf("a passing test logged %q", ft.logs)
+	}
+
+	ft = &fakeT{}
+	log, _ = New(ft)
+	log.Info("first", logger.Int("n", 1))
+	log.Warning("second")
+	ft.Errorf("the test failed")
+	ft.end()
+	if len(ft.logs) != 1 {
+		t.Fatalf("a failing test logged %q, want the entries once", ft.logs)
+	}
+	dump := ft.logs[0]
+	for _, want := range []string{"2 entries were logged", "first", "n=1", "second"} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump %q does not contain %q", dump, want)
+		}
+	}
+	if strings.Index(dump, "first") > strings.Index(dump, "second") {
+		t.Errorf("dump %q is not in the order of the entries", dump)
+	}
+
+	ft = &fakeT{}
+	New(ft)
+	ft.Errorf("the test failed")
+	ft.end()
+	if len(ft.logs) != 1 || ft.logs[0] != "logtest: nothing was logged" {
+		t.Errorf("a failing test with no entries logged %q", ft.logs)
+	}
+}
@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// entryCounts counts the entries that go through the channel, by component
+// and level. It is updated by the consumer goroutine and read by the metrics
+// handler.
+type entryCounts struct {
+	mtx    sync.Mutex
+	counts map[string]*[numLevels]int64
+}
+
+func (ec *entryCounts) add(e *Entry) {
+	ec.mtx.Lock()
+	defer ec.mtx.Unlock()
+	if ec.counts == nil {
+		ec.counts = make(map[string]*[numLevels]int64)
+	}
+	counts, ok := ec.counts[e.Component]
+	if !ok {
+		counts = new([numLevels]int64)
+		ec.counts[e.Component] = counts
+	}
+	counts[levelIndex(e.Level)]++
+}
+
+// latencyBuckets are the upper bounds, in seconds, of the histogram buckets.
+var latencyBuckets = [...]float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+// histogram is a lock-free Prometheus histogram of durations.
+type histogram struct {
+	counts [len(latencyBuckets) + 1]atomic.Int64 // Not cumulative, the last one is +Inf
+	sum    atomic.Int64                          // Nanoseconds
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i, _ := slices.BinarySearch(latencyBuckets[:], d.Seconds())
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+// MetricsHandler serves the logger counters in the Prometheus text format,
+// so dashboards can alert on error rates without parsing the logs:
+//
+//	http.Handle("/metrics", log.MetricsHandler())
+//
+// Entries are counted when the consumer goroutine takes them off the
+// channel, so entries dropped by the backpressure policy only show up in
+// logger_dropped_total.
+func (l *Logger) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		l.core.writeMetrics(bw)
+		bw.Flush()
+	})
+}
+
+func (c *core) writeMetrics(w *bufio.Writer) {
+	header := func(name, typ, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	header("logger_entries_total", "counter", "Entries taken off the channel, by level and component.")
+	c.counts.mtx.Lock()
+	components := make([]string, 0, len(c.counts.counts))
+	for name := range c.counts.counts {
+		components = append(components, name)
+	}
+	slices.Sort(components)
+	for _, name := range components {
+		for i, n := range c.counts.counts[name] {
+			if n > 0 {
+				fmt.Fprintf(w, "logger_entries_total{level=%s,component=%s} %d\n",
+					quoteLabel(levelAt(i).String()), quoteLabel(name), n)
+			}
+		}
+	}
+	c.counts.mtx.Unlock()
+
+	header("logger_dropped_total", "counter", "Entries dropped by the backpressure policy or Shutdown, by level.")
+	for i := range c.dropped {
+		if n := c.dropped[i].Load(); n > 0 {
+			fmt.Fprintf(w, "logger_dropped_total{level=%s} %d\n", quoteLabel(levelAt(i).String()), n)
+		}
+	}
+	if c.dedup != nil {
+		header("logger_suppressed_total", "counter", "Duplicate entries collapsed by WithDedup.")
+		fmt.Fprintf(w, "logger_suppressed_total %d\n", c.dedup.suppressed.Load())
+	}
+	header("logger_queue_length", "gauge", "Entries waiting in the channel.")
+	fmt.Fprintf(w, "logger_queue_length %d\n", len(c.ch))
+	header("logger_queue_capacity", "gauge", "Size of the channel buffer.")
+	fmt.Fprintf(w, "logger_queue_capacity %d\n", cap(c.ch))
+
+	sinkMetric := func(name, typ, help string, value func(s *sinkRunner) int64) {
+		header(name, typ, help)
+		for _, s := range c.sinks {
+			fmt.Fprintf(w, "%s{sink=%s} %d\n", name, quoteLabel(s.name), value(s))
+		}
+	}
+	sinkMetric("logger_sink_written_total", "counter", "Entries written by each sink.",
+		func(s *sinkRunner) int64 { return s.written.Load() })
+	sinkMetric("logger_sink_errors_total", "counter", "Failed writes and flushes of each sink.",
+		func(s *sinkRunner) int64 { return s.errors.Load() })
+	sinkMetric("logger_sink_dropped_total", "counter", "Entries dropped because the sink was failing or too slow.",
+		func(s *sinkRunner) int64 { return s.dropped.Load() })
+	sinkMetric("logger_sink_queue_length", "gauge", "Entries waiting to be written by each sink.",
+		func(s *sinkRunner) int64 { return s.pending.Load() })
+	sinkMetric("logger_sink_failing", "gauge", "1 while the sink is failing.",
+		func(s *sinkRunner) int64 {
+			if s.failing.Load() {
+				return 1
+			}
+			return 0
+		})
+
+	header("logger_sink_write_duration_seconds", "histogram", "Time taken by each WriteEntry call of the sink.")
+	for _, s := range c.sinks {
+		writeHistogram(w, "logger_sink_write_duration_seconds", quoteLabel(s.name), &s.writeLatency)
+	}
+	header("logger_sink_flush_duration_seconds", "histogram", "Time taken to flush the entries buffered by the sink.")
+	for _, s := range c.sinks {
+		if _, ok := s.sink.(Flusher); ok {
+			writeHistogram(w, "logger_sink_flush_duration_seconds", quoteLabel(s.name), &s.flushLatency)
+		}
+	}
+}
+
+func writeHistogram(w *bufio.Writer, name, sink string, h *histogram) {
+	var cumulative int64
+	for i, le := range latencyBuckets {
+		cumulative += h.counts[i].Load()
+		fmt.Fprintf(w, "%s_bucket{sink=%s,le=\"%s\"} %d\n", name, sink, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+	}
+	cumulative += h.counts[len(latencyBuckets)].Load()
+	fmt.Fprintf(w, "%s_bucket{sink=%s,le=\"+Inf\"} %d\n", name, sink, cumulative)
+	fmt.Fprintf(w, "%s_sum{sink=%s} %s\n", name, sink, strconv.FormatFloat(time.Duration(h.sum.Load()).Seconds(), 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count{sink=%s} %d\n", name, sink, cumulative)
+}
+
+// labelEscaper escapes label values as the text format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(s string) string {
+	return `"` + labelEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// RePanic makes RecoverAndFlush panic again with the recovered value instead
+// of exiting.
+const RePanic = -1
+
+// RecoverAndFlush recovers a panic and logs it at LevelFatal, with the stack
+// from where it happened unless WithStackLevel turned stacks off. It then
+// closes the logger, which waits for every buffered entry to be written, and
+// exits with exitCode, or panics again with the same value if exitCode is
+// RePanic. It does nothing when there is no panic. Defer it in main and at
+// the top of goroutines, so the entries logged just before a crash are not
+// lost with the buffer:
+//
+//	func main() {
+//		log := logger.New()
+//		defer log.Close()
+//		defer log.RecoverAndFlush(2)
+//		...
+//	}
+//
+// It must be deferred directly, not called from a deferred function, for
+// recover to see the panic.
+func (l *Logger) RecoverAndFlush(exitCode int) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if l.core.levels.enabled(LevelFatal, l.component) {
+		l.send(&Entry{Time: time.Now(), Level: LevelFatal, Message: "Recovered from a panic"},
+			[]Field{Any("panic", r)}, panicPC())
+	}
+	l.Close()
+	if exitCode == RePanic {
+		panic(r)
+	}
+	os.Exit(exitCode)
+}
+
+// panicPC returns the program counter of the code that panicked: the first
+// frame above the deferred RecoverAndFlush that is not in the runtime, which
+// raises panics for nil dereferences and out of range indexes.
+func panicPC() uintptr {
+	var pcs [maxStack]uintptr
+	n := runtime.Callers(3, pcs[:]) // Skips runtime.Callers, panicPC and RecoverAndFlush
+	for _, pc := range pcs[:n] {
+		if fn := runtime.FuncForPC(pc - 1); fn != nil && !strings.HasPrefix(fn.Name(), "runtime.") {
+			return pc
+		}
+	}
+	return 0
+}
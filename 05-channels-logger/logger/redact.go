@@ -0,0 +1,260 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Redaction configures the stage that removes secrets and personal data from
+// entries. It runs on the consumer goroutine before any other stage, so no
+// sink ever sees the original values.
+type Redaction struct {
+	// Patterns are replaced in the message and in the text of string, error
+	// and fmt.Stringer field values. Stringer fields are formatted here
+	// rather than by the encoder. Other values, such as maps, slices and
+	// structs, are replaced by a copy made through their JSON form when
+	// anything in them is redacted.
+	Patterns []RedactPattern
+	// Fields are the keys whose values are replaced entirely, whatever their
+	// type. Keys match without case, and also as the last part of a dotted key
+	// such as "req.password". They also match the keys of maps and structs
+	// inside field values.
+	Fields []string
+	// HashFields are the keys whose values are replaced by a keyed hash, so
+	// entries of the same user can still be told apart without showing who
+	// they are. They match like Fields.
+	HashFields []string
+	// HashKey is the HMAC key of HashFields. Keep it secret: anyone with the
+	// key can hash guesses and compare them with the logs.
+	HashKey []byte
+	// Mask replaces what is redacted. Defaults to "[REDACTED]".
+	Mask string
+}
+
+// RedactPattern is a regular expression to redact. When it has a
+// parenthesised group, only the text of the first group is replaced.
+type RedactPattern struct {
+	Regexp *regexp.Regexp
+	// Check, if set, is called with each match and must return true for it to
+	// be redacted. It filters out numbers that look like card numbers.
+	Check func(match string) bool
+}
+
+var (
+	// RedactCardNumbers matches payment card numbers that pass the Luhn check,
+	// with or without spaces or dashes between the digits.
+	RedactCardNumbers = RedactPattern{
+		Regexp: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Check:  luhn,
+	}
+	// RedactBearerTokens matches the token of "Bearer <token>", as found in
+	// Authorization headers.
+	RedactBearerTokens = RedactPattern{
+		Regexp: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+	}
+	// RedactEmails matches email addresses.
+	RedactEmails = RedactPattern{
+		Regexp: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	}
+)
+
+// WithRedaction removes secrets and personal data from the entries before
+// they reach the sinks.
+//
+//	logger.WithRedaction(logger.Redaction{
+//		Patterns:   []logger.RedactPattern{logger.RedactCardNumbers, logger.RedactBearerTokens, logger.RedactEmails},
+//		Fields:     []string{"password", "authorization"},
+//		HashFields: []string{"user_id"},
+//		HashKey:    key,
+//	})
+func WithRedaction(r Redaction) Option {
+	return func(c *config) {
+		if r.Mask == "" {
+			r.Mask = "[REDACTED]"
+		}
+		c.redaction = &r
+	}
+}
+
+// redactor runs on the consumer goroutine.
+type redactor struct {
+	Redaction
+	fields     map[string]bool // Lowercase keys
+	hashFields map[string]bool
+}
+
+func newRedactor(r *Redaction) *redactor {
+	rd := &redactor{Redaction: *r, fields: make(map[string]bool), hashFields: make(map[string]bool)}
+	for _, k := range r.Fields {
+		rd.fields[strings.ToLower(k)] = true
+	}
+	for _, k := range r.HashFields {
+		rd.hashFields[strings.ToLower(k)] = true
+	}
+	return rd
+}
+
+// redact rewrites the entry in place. Its fields slice belongs to the entry,
+// see Logger.send, so the fields of the logger that created it are not
+// touched.
+func (rd *redactor) redact(e *Entry) {
+	e.Message = rd.redactString(e.Message)
+	for i := range e.Fields {
+		f := &e.Fields[i]
+		switch {
+		case rd.matchKey(rd.fields, f.Key):
+			*f = String(f.Key, rd.Mask)
+		case rd.matchKey(rd.hashFields, f.Key):
+			*f = String(f.Key, rd.hash(f.text()))
+		default:
+			var s string
+			switch f.Kind {
+			case KindString, KindError, KindStringer:
+				s = f.text()
+			case KindAny:
+				switch v := f.any.(type) {
+				case error, fmt.Stringer:
+					s = formatValue(v)
+				default:
+					if r, ok := rd.redactAny(v); ok {
+						*f = Any(f.Key, r)
+					}
+					continue
+				}
+			default:
+				continue // Numbers, times and durations
+			}
+			if r := rd.redactString(s); r != s || f.Kind == KindStringer {
+				*f = String(f.Key, r)
+			}
+		}
+	}
+}
+
+// redactAny redacts a value of another type, such as a map, slice or struct,
+// which the encoders would write as it is. It works on a copy made through
+// its JSON form, so the value of the caller is not modified, and reports
+// whether anything was redacted. Values JSON cannot encode are redacted as
+// text.
+func (rd *redactor) redactAny(v any) (any, bool) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		s := formatValue(v)
+		r := rd.redactString(s)
+		return r, r != s
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var copied any
+	if err := dec.Decode(&copied); err != nil {
+		return nil, false
+	}
+	return rd.redactValue(copied)
+}
+
+// redactValue redacts a value decoded from JSON in place: the keys of
+// objects are matched like those of fields, and patterns are replaced in
+// strings.
+func (rd *redactor) redactValue(v any) (any, bool) {
+	changed := false
+	switch v := v.(type) {
+	case string:
+		r := rd.redactString(v)
+		return r, r != v
+	case []any:
+		for i, x := range v {
+			if r, ok := rd.redactValue(x); ok {
+				v[i], changed = r, true
+			}
+		}
+	case map[string]any:
+		for k, x := range v {
+			switch {
+			case rd.matchKey(rd.fields, k):
+				v[k], changed = rd.Mask, true
+			case rd.matchKey(rd.hashFields, k):
+				v[k], changed = rd.hash(formatValue(x)), true
+			default:
+				if r, ok := rd.redactValue(x); ok {
+					v[k], changed = r, true
+				}
+			}
+		}
+	}
+	return v, changed
+}
+
+func (rd *redactor) matchKey(keys map[string]bool, key string) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	key = strings.ToLower(key)
+	if keys[key] {
+		return true
+	}
+	i := strings.LastIndexByte(key, '.')
+	return i >= 0 && keys[key[i+1:]]
+}
+
+func (rd *redactor) redactString(s string) string {
+	for _, p := range rd.Patterns {
+		matches := p.Regexp.FindAllStringSubmatchIndex(s, -1)
+		if matches == nil {
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3] // Only the first group
+			}
+			if p.Check != nil && !p.Check(s[start:end]) {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(rd.Mask)
+			last = end
+		}
+		if last > 0 {
+			b.WriteString(s[last:])
+			s = b.String()
+		}
+	}
+	return s
+}
+
+// hash returns a short HMAC-SHA256 of s, the same for the same s and key.
+func (rd *redactor) hash(s string) string {
+	mac := hmac.New(sha256.New, rd.HashKey)
+	mac.Write([]byte(s))
+	return "hmac:" + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum used by card
+// numbers.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,160 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestRedactor() *redactor {
+	return newRedactor(&Redaction{
+		Patterns:   []RedactPattern{RedactCardNumbers, RedactBearerTokens, RedactEmails},
+		Fields:     []string{"password", "Authorization"},
+		HashFields: []string{"user_id"},
+		HashKey:    []byte("test key"),
+		Mask:       "[REDACTED]",
+	})
+}
+
+// fieldText returns the text of the field of e with key, as a text encoder
+// writes it.
+func fieldText(t *testing.T, e *Entry, key string) string {
+	t.Helper()
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return f.text()
+		}
+	}
+	t.Fatalf("entry has no field %q", key)
+	return ""
+}
+
+func TestRedactMasksFieldsByKey(t *testing.T) {
+	rd := newTestRedactor()
+	e := &Entry{Message: "login", Fields: []Field{
+		String("password", "hunter2"),
+		String("req.PASSWORD", "hunter2"),
+		Int("authorization", 42),
+		String("user", "ann"),
+	}}
+	rd.redact(e)
+	for _, key := range []string{"password", "req.PASSWORD", "authorization"} {
+		if got := fieldText(t, e, key); got != "[REDACTED]" {
+			t.Errorf("%s is %q, want it masked", key, got)
+		}
+	}
+	if got := fieldText(t, e, "user"); got != "ann" {
+		t.Errorf("user is %q, want it unchanged", got)
+	}
+}
+
+func TestRedactHashesFieldsByKey(t *testing.T) {
+	rd := newTestRedactor()
+	a := &Entry{Fields: []Field{Int("user_id", 42)}}
+	b := &Entry{Fields: []Field{String("user_id", "42")}}
+	c := &Entry{Fields: []Field{Int("user_id", 43)}}
+	for _, e := range []*Entry{a, b, c} {
+		rd.redact(e)
+	}
+	ha, hb, hc := fieldText(t, a, "user_id"), fieldText(t, b, "user_id"), fieldText(t, c, "user_id")
+	if !strings.HasPrefix(ha, "hmac:") || strings.Contains(ha, "42") {
+		t.Fatalf("user_id is %q, want a hash", ha)
+	}
+	if ha != hb {
+		t.Errorf("the same user gave %q and %q", ha, hb)
+	}
+	if ha == hc {
+		t.Errorf("different users gave the same hash %q", ha)
+	}
+}
+
+func TestRedactPatternsInMessagesAndStrings(t *testing.T) {
+	rd := newTestRedactor()
+	e := &Entry{
+		Message: "paid with 4111 1111 1111 1111 by ann@example.com, order 1234567890123",
+		Fields:  []Field{String("header", "Bearer abc.def")},
+	}
+	rd.redact(e)
+	if want := "paid with [REDACTED] by [REDACTED], order 1234567890123"; e.Message != want {
+		t.Errorf("message is %q, want %q", e.Message, want)
+	}
+	if got, want := fieldText(t, e, "header"), "Bearer [REDACTED]"; got != want {
+		t.Errorf("header is %q, want %q", got, want)
+	}
+}
+
+type testStringer string
+
+func (s testStringer) String() string { return string(s) }
+
+func TestRedactStringersErrorsAndOtherValues(t *testing.T) {
+	rd := newTestRedactor()
+	type credentials struct {
+		User     string
+		Password string
+	}
+	e := &Entry{Fields: []Field{
+		Stringer("stringer", testStringer("mail ann@example.com")),
+		Err(errors.New("bad token Bearer xyz")),
+		Any("any_error", errors.New("card 4111111111111111")),
+		Any("headers", map[string]string{"Authorization": "Bearer abc", "password": "hunter2", "Accept": "text/plain"}),
+		Any("list", []string{"Bearer xyz", "plain"}),
+		Any("creds", credentials{User: "ann", Password: "hunter2"}),
+		Any("nested", map[string]any{"req": map[string]any{"user_id": 42, "tokens": []any{"Bearer t1"}}}),
+		Any("unencodable", func() {}),
+	}}
+	rd.redact(e)
+	want := map[string]string{
+		"stringer":  "mail [REDACTED]",
+		"error":     "bad token Bearer [REDACTED]",
+		"any_error": "card [REDACTED]",
+		"list":      "[Bearer [REDACTED] plain]",
+	}
+	for key, w := range want {
+		if got := fieldText(t, e, key); got != w {
+			t.Errorf("%s is %q, want %q", key, got, w)
+		}
+	}
+	for _, key := range []string{"headers", "creds", "nested"} {
+		got := fieldText(t, e, key)
+		for _, secret := range []string{"hunter2", "abc", "t1", ":42"} {
+			if strings.Contains(got, secret) {
+				t.Errorf("%s is %q, which shows %q", key, got, secret)
+			}
+		}
+	}
+	if got := fieldText(t, e, "headers"); !strings.Contains(got, "Accept:text/plain") {
+		t.Errorf("headers is %q, want the other keys kept", got)
+	}
+	if got := fieldText(t, e, "creds"); !strings.Contains(got, "User:ann") {
+		t.Errorf("creds is %q, want the other fields kept", got)
+	}
+}
+
+func TestRedactDoesNotModifyTheFieldsOfTheCaller(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(WithOutput(&buf), WithEncoder(&JSONEncoder{}), WithRedaction(Redaction{
+		Patterns: []RedactPattern{RedactBearerTokens},
+		Fields:   []string{"password"},
+	}))
+	headers := map[string]string{"password": "hunter2", "auth": "Bearer abc"}
+	child := l.With(String("token", "Bearer abc"), Any("headers", headers))
+	child.Info("first")
+	child.Info("second")
+	l.Close()
+
+	if headers["password"] != "hunter2" || headers["auth"] != "Bearer abc" {
+		t.Errorf("the map of the caller was modified: %v", headers)
+	}
+	if v := child.fields[0].str; v != "Bearer abc" {
+		t.Errorf("the fields of the logger were modified: token is %q", v)
+	}
+	out := buf.String()
+	if strings.Contains(out, "hunter2") || strings.Contains(out, "abc") {
+		t.Errorf("output shows a secret:\n%s", out)
+	}
+	if n := strings.Count(out, `"password":"[REDACTED]"`); n != 2 {
+		t.Errorf("password was masked in %d entries, want 2:\n%s", n, out)
+	}
+}
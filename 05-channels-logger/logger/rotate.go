@@ -0,0 +1,320 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RotateEvery is the wall-clock boundary at which a RotatingFileSink starts a
+// new file.
+type RotateEvery int
+
+const (
+	RotateNever RotateEvery = iota
+	RotateHourly
+	RotateDaily
+)
+
+// RotateConfig configures a RotatingFileSink. Only Path is required.
+type RotateConfig struct {
+	Path     string
+	Encoder  Encoder       // Defaults to a TextEncoder
+	MaxBytes int64         // Rotates before the file grows past this size, 0 disables it
+	Every    RotateEvery   // Rotates at every hour or day boundary
+	Compress bool          // Gzips rotated files in the background
+	MaxFiles int           // Rotated files to keep, 0 keeps all of them
+	MaxAge   time.Duration // Deletes rotated files older than this, 0 keeps all of them
+
+	// ReopenOnSIGHUP reopens Path when the process receives SIGHUP, for when
+	// something else moves the file away. Logger.HandleSignals leaves the
+	// sink alone then, so the file is reopened once per signal.
+	ReopenOnSIGHUP bool
+}
+
+// rotatedLayout is inserted between the name and the extension of rotated
+// files. It sorts in chronological order and has no characters that are
+// invalid in file names.
+const rotatedLayout = "2006-01-02T15-04-05.000"
+
+// RotatingFileSink writes entries to a file and moves it aside when it gets
+// too big or a time boundary is crossed. Rotated files are named after the
+// time of the rotation, e.g. app-2006-01-02T15-04-05.000.log.gz.
+type RotatingFileSink struct {
+	cfg       RotateConfig
+	dir       string
+	prefix    string // Base name without extension, followed by "-"
+	ext       string
+	mtx       sync.Mutex // Guards the file, Reopen can be called from any goroutine
+	file      *os.File
+	closed    bool
+	size      int64
+	nextRoll  time.Time // Zero when time-based rotation is disabled
+	cleanupCh chan struct{}
+	cleanupWg sync.WaitGroup
+	hupCh     chan os.Signal
+}
+
+// NewRotatingFileSink opens (or creates) cfg.Path and starts the background
+// goroutine that compresses and deletes rotated files.
+func NewRotatingFileSink(cfg RotateConfig) (*RotatingFileSink, error) {
+	if cfg.Encoder == nil {
+		cfg.Encoder = &TextEncoder{}
+	}
+	base := filepath.Base(cfg.Path)
+	ext := filepath.Ext(base)
+	s := &RotatingFileSink{
+		cfg:       cfg,
+		dir:       filepath.Dir(cfg.Path),
+		prefix:    strings.TrimSuffix(base, ext) + "-",
+		ext:       ext,
+		cleanupCh: make(chan struct{}, 1),
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	s.cleanupWg.Add(1)
+	go s.cleanup()
+	s.cleanupCh <- struct{}{} // Deals with whatever previous runs left behind
+	if cfg.ReopenOnSIGHUP {
+		s.hupCh = make(chan os.Signal, 1)
+		signal.Notify(s.hupCh, syscall.SIGHUP)
+		go func() {
+			for range s.hupCh {
+				s.Reopen()
+			}
+		}()
+	}
+	return s, nil
+}
+
+func (s *RotatingFileSink) open() error {
+	f, err := os.OpenFile(s.cfg.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.size = info.Size()
+	s.nextRoll = nextBoundary(time.Now(), s.cfg.Every)
+	return nil
+}
+
+func nextBoundary(now time.Time, every RotateEvery) time.Time {
+	switch every {
+	case RotateHourly:
+		return time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, now.Location())
+	case RotateDaily:
+		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Time{}
+}
+
+func (s *RotatingFileSink) WriteEntry(e *Entry) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	*buf = s.cfg.Encoder.Encode(*buf, e)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	if s.file == nil {
+		if err := s.open(); err != nil { // A previous rotation or reopen failed
+			return err
+		}
+	}
+	now := time.Now()
+	tooBig := s.cfg.MaxBytes > 0 && s.size > 0 && s.size+int64(len(*buf)) > s.cfg.MaxBytes
+	tooOld := !s.nextRoll.IsZero() && !now.Before(s.nextRoll)
+	if tooBig || tooOld {
+		if err := s.rotate(now); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(*buf)
+	s.size += int64(n)
+	return err
+}
+
+// rotate moves the current file aside and opens a new one.
+func (s *RotatingFileSink) rotate(now time.Time) error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+	name := s.rotatedName(now)
+	for i := 1; fileExists(name); i++ { // Several rotations in the same millisecond
+		name = s.rotatedName(now.Add(time.Duration(i) * time.Millisecond))
+	}
+	if err := os.Rename(s.cfg.Path, name); err != nil {
+		return err
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	select {
+	case s.cleanupCh <- struct{}{}:
+	default: // A cleanup is already pending and will see this file too
+	}
+	return nil
+}
+
+func (s *RotatingFileSink) rotatedName(t time.Time) string {
+	return filepath.Join(s.dir, s.prefix+t.Format(rotatedLayout)+s.ext)
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	if err == nil {
+		return true
+	}
+	_, err = os.Stat(name + ".gz")
+	return err == nil
+}
+
+// Reopen closes and reopens the file at the configured path, so entries go to
+// a new file once an external tool such as logrotate has renamed the old one.
+func (s *RotatingFileSink) Reopen() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	return s.open()
+}
+
+func (s *RotatingFileSink) handlesSIGHUP() bool {
+	return s.cfg.ReopenOnSIGHUP
+}
+
+// Close closes the current file and waits for the pending compression and
+// cleanup to finish.
+func (s *RotatingFileSink) Close() error {
+	if s.hupCh != nil {
+		signal.Stop(s.hupCh)
+		close(s.hupCh)
+	}
+	s.mtx.Lock()
+	s.closed = true
+	var err error
+	if s.file != nil {
+		err = s.file.Close()
+		s.file = nil
+	}
+	s.mtx.Unlock()
+	close(s.cleanupCh)
+	s.cleanupWg.Wait()
+	return err
+}
+
+// cleanup compresses and deletes rotated files every time it is signalled.
+// It runs in its own goroutine so writes never wait for gzip.
+func (s *RotatingFileSink) cleanup() {
+	defer s.cleanupWg.Done()
+	for range s.cleanupCh {
+		rotated := s.rotatedFiles()
+		if s.cfg.Compress {
+			for i, r := range rotated {
+				if !strings.HasSuffix(r.path, ".gz") && compressFile(r.path) == nil {
+					rotated[i].path += ".gz"
+				}
+			}
+		}
+		s.prune(rotated)
+	}
+}
+
+type rotatedFile struct {
+	path string
+	time time.Time
+}
+
+// rotatedFiles lists the files created by rotate, oldest first.
+func (s *RotatingFileSink) rotatedFiles() []rotatedFile {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	var files []rotatedFile
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".gz")
+		if e.IsDir() || !strings.HasPrefix(name, s.prefix) || !strings.HasSuffix(name, s.ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, s.prefix), s.ext)
+		t, err := time.ParseInLocation(rotatedLayout, stamp, time.Local)
+		if err != nil {
+			continue // Not one of ours
+		}
+		files = append(files, rotatedFile{filepath.Join(s.dir, e.Name()), t})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].time.Before(files[j].time)
+	})
+	return files
+}
+
+func (s *RotatingFileSink) prune(files []rotatedFile) {
+	if s.cfg.MaxFiles > 0 && len(files) > s.cfg.MaxFiles {
+		for _, f := range files[:len(files)-s.cfg.MaxFiles] {
+			os.Remove(f.path)
+		}
+		files = files[len(files)-s.cfg.MaxFiles:]
+	}
+	if s.cfg.MaxAge > 0 {
+		cutoff := time.Now().Add(-s.cfg.MaxAge)
+		for _, f := range files {
+			if f.time.Before(cutoff) {
+				os.Remove(f.path)
+			}
+		}
+	}
+}
+
+// compressFile replaces path with path.gz. The compressed file is written
+// under a temporary name first, so a crash never leaves a truncated .gz.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := path + ".gz.tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("compressing %s: %w", path, err)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,509 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	cryptorand "crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The shipper protocol is line based, so it can be debugged with netcat:
+//
+//	shipper   -> collector  HELLO <sender-id>
+//	collector -> shipper    ACK <last-seq>      (last record it has from this sender)
+//	shipper   -> collector  <seq> <entry>       (one per record, seq always grows)
+//	collector -> shipper    ACK <seq>           (every time it has written what it got)
+//
+// Records are only deleted from the spool once they are acknowledged, and the
+// collector ignores records it already has, so a reconnect replays the spool
+// in order and without duplicates.
+
+// ErrSpoolFull is returned by ShipperSink when the spool has reached its
+// maximum size, so the entry could not be kept.
+var ErrSpoolFull = errors.New("logger: shipper spool is full")
+
+// ShipperConfig configures a ShipperSink. Addr and SpoolDir are required.
+type ShipperConfig struct {
+	Addr         string        // TCP address of the collector
+	SpoolDir     string        // Where records wait until the collector acknowledges them
+	Encoder      Encoder       // Must write one line per entry, defaults to a JSONEncoder
+	MaxSpool     int64         // Maximum size of the spool, defaults to 64 MiB
+	MinBackoff   time.Duration // First wait after a failed connection, defaults to 100ms
+	MaxBackoff   time.Duration // Longest wait between connections, defaults to 30s
+	DrainTimeout time.Duration // How long Close waits for the spool to be sent, defaults to 5s
+}
+
+// ShipperSink streams entries to a remote collector, such as the one in
+// cmd/logcollector. Every entry is written to an on-disk spool first, and a
+// separate goroutine sends the spool over TCP, reconnecting with exponential
+// backoff. Whatever is not acknowledged when the sink closes stays in the
+// spool and is sent by the next ShipperSink using the same directory.
+type ShipperSink struct {
+	cfg   ShipperConfig
+	spool *spool
+
+	quit chan struct{}
+	done chan struct{}
+
+	mtx  sync.Mutex // Guards conn, so Close can interrupt the sender
+	conn net.Conn
+}
+
+// NewShipperSink opens the spool in cfg.SpoolDir and starts sending it.
+func NewShipperSink(cfg ShipperConfig) (*ShipperSink, error) {
+	if cfg.Encoder == nil {
+		cfg.Encoder = &JSONEncoder{}
+	}
+	if cfg.MaxSpool <= 0 {
+		cfg.MaxSpool = 64 << 20
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 30 * time.Second
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = 5 * time.Second
+	}
+	sp, err := openSpool(cfg.SpoolDir, cfg.MaxSpool)
+	if err != nil {
+		return nil, err
+	}
+	s := &ShipperSink{
+		cfg:   cfg,
+		spool: sp,
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go s.send()
+	return s, nil
+}
+
+func (s *ShipperSink) WriteEntry(e *Entry) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	*buf = s.cfg.Encoder.Encode(*buf, e)
+	return s.spool.append(bytes.TrimRight(*buf, "\n"))
+}
+
+// Close waits up to DrainTimeout for the collector to acknowledge the spool,
+// then stops the sender.
+func (s *ShipperSink) Close() error {
+	timer := time.NewTimer(s.cfg.DrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-s.spool.drained():
+	case <-timer.C:
+	}
+	close(s.quit)
+	s.mtx.Lock()
+	if s.conn != nil {
+		s.conn.Close() // Unblocks the sender if it is waiting on the network
+	}
+	s.mtx.Unlock()
+	<-s.done
+	return s.spool.close()
+}
+
+// send runs on its own goroutine, connecting to the collector until the sink
+// is closed.
+func (s *ShipperSink) send() {
+	defer close(s.done)
+	backoff := s.cfg.MinBackoff
+	for {
+		err := s.session(func() { backoff = s.cfg.MinBackoff })
+		select {
+		case <-s.quit:
+			return
+		default:
+		}
+		if err != nil {
+			// Jitter stops every node from reconnecting at the same time
+			wait := backoff/2 + rand.N(backoff/2+1)
+			select {
+			case <-time.After(wait):
+			case <-s.quit:
+				return
+			}
+			backoff = min(backoff*2, s.cfg.MaxBackoff)
+		}
+	}
+}
+
+// session sends records over a single connection until it fails.
+func (s *ShipperSink) session(connected func()) error {
+	conn, err := net.DialTimeout("tcp", s.cfg.Addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	s.mtx.Lock()
+	select {
+	case <-s.quit:
+		s.mtx.Unlock()
+		conn.Close()
+		return nil
+	default:
+	}
+	s.conn = conn
+	s.mtx.Unlock()
+	defer func() {
+		s.mtx.Lock()
+		s.conn = nil
+		s.mtx.Unlock()
+		conn.Close()
+	}()
+
+	if _, err := fmt.Fprintf(conn, "HELLO %s\n", s.spool.id); err != nil {
+		return err
+	}
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	last, err := readAck(r)
+	if err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Time{})
+	s.spool.ack(last)
+	connected()
+
+	ackErr := make(chan error, 1)
+	go func() { // Acks arrive while records are still being sent
+		for {
+			seq, err := readAck(r)
+			if err != nil {
+				ackErr <- err
+				return
+			}
+			s.spool.ack(seq)
+		}
+	}()
+
+	cur := s.spool.cursorAfter(last)
+	w := bufio.NewWriter(conn)
+	for {
+		data, next, err := s.spool.read(cur)
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 {
+			if err := writeRecords(w, data, last); err != nil {
+				return err
+			}
+			cur = next
+			continue
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		cur = next
+		select {
+		case <-s.spool.notify:
+		case err := <-ackErr:
+			return err
+		case <-s.quit:
+			return nil
+		}
+	}
+}
+
+// writeRecords writes the records in data, skipping the ones the collector
+// already had when the session started.
+func writeRecords(w *bufio.Writer, data []byte, skipUpTo uint64) error {
+	for len(data) > 0 {
+		line, rest, _ := bytes.Cut(data, []byte{'\n'})
+		data = rest
+		if seq, _ := parseRecordSeq(line); seq <= skipUpTo {
+			continue
+		}
+		w.Write(line)
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseRecordSeq(line []byte) (uint64, bool) {
+	n, _, ok := bytes.Cut(line, []byte{' '})
+	if !ok {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(string(n), 10, 64)
+	return seq, err == nil
+}
+
+func readAck(r *bufio.Reader) (uint64, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	n, ok := strings.CutPrefix(strings.TrimSpace(line), "ACK ")
+	if !ok {
+		return 0, fmt.Errorf("logger: unexpected reply from collector: %q", line)
+	}
+	return strconv.ParseUint(n, 10, 64)
+}
+
+// spoolSegmentSize is the size at which the spool starts a new segment, so
+// acknowledged records can be deleted a segment at a time.
+const spoolSegmentSize = 1 << 20
+
+// spool is an append-only queue of records on disk, split into segment files
+// named after the sequence number of their first record.
+type spool struct {
+	dir    string
+	id     string // Identifies this spool to the collector
+	max    int64
+	notify chan struct{} // Signalled after every append
+
+	mtx      sync.Mutex
+	segments []*spoolSegment // Oldest first, the last one is being appended to
+	file     *os.File        // The last segment, open for appending
+	size     int64           // Of all segments
+	nextSeq  uint64
+	acked    uint64
+	drainCh  chan struct{} // Closed when everything written has been acked
+}
+
+type spoolSegment struct {
+	first uint64
+	path  string
+	size  int64 // Only counts complete records
+}
+
+// spoolCursor is a position in the spool.
+type spoolCursor struct {
+	first  uint64 // First sequence number of the segment
+	offset int64
+}
+
+func openSpool(dir string, max int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	id, err := spoolID(dir)
+	if err != nil {
+		return nil, err
+	}
+	sp := &spool{dir: dir, id: id, max: max, notify: make(chan struct{}, 1), nextSeq: 1}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.spool"))
+	for _, p := range paths {
+		first, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(p), ".spool"), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		sp.segments = append(sp.segments, &spoolSegment{first, p, info.Size()})
+	}
+	sort.Slice(sp.segments, func(i, j int) bool {
+		return sp.segments[i].first < sp.segments[j].first
+	})
+	if n := len(sp.segments); n > 0 {
+		if err := sp.recoverTail(sp.segments[n-1]); err != nil {
+			return nil, err
+		}
+		sp.acked = sp.segments[0].first - 1 // Unknown until the collector says, but older ones were deleted
+	}
+	for _, seg := range sp.segments {
+		sp.size += seg.size
+	}
+	if err := sp.openLast(); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// spoolID reads the sender ID of the spool, creating one the first time.
+func spoolID(dir string) (string, error) {
+	path := filepath.Join(dir, "sender-id")
+	if b, err := os.ReadFile(path); err == nil {
+		return strings.TrimSpace(string(b)), nil
+	}
+	b := make([]byte, 16)
+	if _, err := cryptorand.Read(b); err != nil {
+		return "", err
+	}
+	id := fmt.Sprintf("%x", b)
+	return id, os.WriteFile(path, []byte(id+"\n"), 0o644)
+}
+
+// recoverTail drops a record that was only partially written when the
+// process died, and finds the next sequence number.
+func (sp *spool) recoverTail(seg *spoolSegment) error {
+	data, err := os.ReadFile(seg.path)
+	if err != nil {
+		return err
+	}
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if end < len(data) {
+		if err := os.Truncate(seg.path, int64(end)); err != nil {
+			return err
+		}
+	}
+	seg.size = int64(end)
+	sp.nextSeq = seg.first
+	if end > 0 {
+		lastLine := data[bytes.LastIndexByte(data[:end-1], '\n')+1 : end-1]
+		if seq, ok := parseRecordSeq(lastLine); ok {
+			sp.nextSeq = seq + 1
+		}
+	}
+	return nil
+}
+
+func (sp *spool) openLast() error {
+	if len(sp.segments) == 0 || sp.segments[len(sp.segments)-1].size >= spoolSegmentSize {
+		seg := &spoolSegment{first: sp.nextSeq, path: filepath.Join(sp.dir, fmt.Sprintf("%020d.spool", sp.nextSeq))}
+		sp.segments = append(sp.segments, seg)
+	}
+	if sp.file != nil {
+		sp.file.Close()
+	}
+	f, err := os.OpenFile(sp.segments[len(sp.segments)-1].path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	sp.file = f
+	return nil
+}
+
+func (sp *spool) append(payload []byte) error {
+	sp.mtx.Lock()
+	defer sp.mtx.Unlock()
+	record := strconv.AppendUint(nil, sp.nextSeq, 10)
+	record = append(record, ' ')
+	record = append(record, payload...)
+	record = append(record, '\n')
+	if sp.size+int64(len(record)) > sp.max {
+		return ErrSpoolFull
+	}
+	seg := sp.segments[len(sp.segments)-1]
+	if seg.size >= spoolSegmentSize {
+		if err := sp.openLast(); err != nil {
+			return err
+		}
+		seg = sp.segments[len(sp.segments)-1]
+	}
+	n, err := sp.file.Write(record)
+	if err != nil {
+		if n > 0 {
+			sp.file.Truncate(seg.size) // Never leave half a record behind
+		}
+		return err
+	}
+	seg.size += int64(n)
+	sp.size += int64(n)
+	sp.nextSeq++
+	select {
+	case sp.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// cursorAfter returns a cursor at the start of the segment holding seq+1.
+func (sp *spool) cursorAfter(seq uint64) spoolCursor {
+	sp.mtx.Lock()
+	defer sp.mtx.Unlock()
+	cur := spoolCursor{first: sp.segments[0].first}
+	for _, seg := range sp.segments {
+		if seg.first <= seq+1 {
+			cur.first = seg.first
+		}
+	}
+	return cur
+}
+
+// read returns the complete records after cur and the cursor after them.
+// When cur is at the end of a segment, it moves on to the next one.
+func (sp *spool) read(cur spoolCursor) ([]byte, spoolCursor, error) {
+	sp.mtx.Lock()
+	defer sp.mtx.Unlock()
+	for i, seg := range sp.segments {
+		if seg.first < cur.first {
+			continue
+		}
+		if seg.first > cur.first {
+			cur = spoolCursor{first: seg.first} // Our segment was acked and deleted meanwhile
+		}
+		if cur.offset >= seg.size {
+			if i == len(sp.segments)-1 {
+				return nil, cur, nil // Up to date, wait for more
+			}
+			cur = spoolCursor{first: sp.segments[i+1].first}
+			continue
+		}
+		f, err := os.Open(seg.path)
+		if err != nil {
+			return nil, cur, err
+		}
+		defer f.Close()
+		left := seg.size - cur.offset
+		for window := int64(256 << 10); ; window *= 2 {
+			data := make([]byte, min(left, window))
+			n, err := f.ReadAt(data, cur.offset)
+			if err != nil && err != io.EOF {
+				return nil, cur, err
+			}
+			end := bytes.LastIndexByte(data[:n], '\n') + 1 // Only whole records
+			if end == 0 && int64(n) < left {
+				continue // A record longer than the window, read more of it
+			}
+			cur.offset += int64(end)
+			return data[:end], cur, nil
+		}
+	}
+	return nil, cur, nil
+}
+
+// ack deletes the segments whose records have all been acknowledged.
+func (sp *spool) ack(seq uint64) {
+	sp.mtx.Lock()
+	defer sp.mtx.Unlock()
+	if seq > sp.acked {
+		sp.acked = seq
+	}
+	for len(sp.segments) > 1 && sp.segments[1].first-1 <= sp.acked {
+		os.Remove(sp.segments[0].path)
+		sp.size -= sp.segments[0].size
+		sp.segments = sp.segments[1:]
+	}
+	if sp.drainCh != nil && sp.acked+1 >= sp.nextSeq {
+		close(sp.drainCh)
+		sp.drainCh = nil
+	}
+}
+
+// drained returns a channel closed once every record has been acknowledged.
+func (sp *spool) drained() <-chan struct{} {
+	sp.mtx.Lock()
+	defer sp.mtx.Unlock()
+	ch := make(chan struct{})
+	if sp.acked+1 >= sp.nextSeq {
+		close(ch)
+		return ch
+	}
+	sp.drainCh = ch
+	return ch
+}
+
+func (sp *spool) close() error {
+	sp.mtx.Lock()
+	defer sp.mtx.Unlock()
+	return sp.file.Close()
+}
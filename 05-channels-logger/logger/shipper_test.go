@@ -0,0 +1,229 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCollector speaks the collector side of the shipper protocol on a local
+// listener, remembering the last record of each sender like cmd/logcollector.
+type fakeCollector struct {
+	ln net.Listener
+
+	mtx     sync.Mutex
+	last    map[string]uint64
+	records []string // Payloads, without their sequence number
+	resent  int      // Records the collector already had
+	changed chan struct{}
+}
+
+func newFakeCollector(t *testing.T) *fakeCollector {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &fakeCollector{ln: ln, last: make(map[string]uint64), changed: make(chan struct{}, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go c.serve(conn)
+		}
+	}()
+	return c
+}
+
+func (c *fakeCollector) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReaderSize(conn, 1<<20)
+	hello, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	sender := strings.TrimPrefix(strings.TrimSpace(hello), "HELLO ")
+	c.mtx.Lock()
+	last := c.last[sender]
+	c.mtx.Unlock()
+	fmt.Fprintf(conn, "ACK %d\n", last)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		seq, ok := parseRecordSeq(line)
+		if !ok {
+			return
+		}
+		c.mtx.Lock()
+		if seq > c.last[sender] {
+			_, payload, _ := bytes.Cut(bytes.TrimRight(line, "\n"), []byte{' '})
+			c.records = append(c.records, string(payload))
+			c.last[sender] = seq
+		} else {
+			c.resent++
+		}
+		c.mtx.Unlock()
+		select {
+		case c.changed <- struct{}{}:
+		default:
+		}
+		fmt.Fprintf(conn, "ACK %d\n", seq)
+	}
+}
+
+// waitRecords waits until the collector has n records and returns them.
+func (c *fakeCollector) waitRecords(t *testing.T, n int) []string {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		c.mtx.Lock()
+		records := append([]string(nil), c.records...)
+		c.mtx.Unlock()
+		if len(records) >= n {
+			return records
+		}
+		select {
+		case <-c.changed:
+		case <-timeout:
+			t.Fatalf("collector got %d records, want %d", len(records), n)
+		}
+	}
+}
+
+func newTestShipper(t *testing.T, addr, dir string) *ShipperSink {
+	t.Helper()
+	s, err := NewShipperSink(ShipperConfig{
+		Addr:       addr,
+		SpoolDir:   dir,
+		Encoder:    &TextEncoder{},
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestShipperSendsRecordsInOrder(t *testing.T) {
+	c := newFakeCollector(t)
+	s := newTestShipper(t, c.ln.Addr().String(), t.TempDir())
+	for i := 0; i < 100; i++ {
+		if err := s.WriteEntry(&Entry{Time: time.Now(), Message: fmt.Sprintf("entry %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := c.waitRecords(t, 100)
+	s.Close()
+	for i, r := range records {
+		if want := fmt.Sprintf("entry %d", i); !strings.Contains(r, want) {
+			t.Fatalf("record %d is %q, want %q", i, r, want)
+		}
+	}
+}
+
+func TestShipperSendsRecordsLargerThanAReadWindow(t *testing.T) {
+	c := newFakeCollector(t)
+	s := newTestShipper(t, c.ln.Addr().String(), t.TempDir())
+	defer s.Close()
+	big := strings.Repeat("x", 300<<10)
+	s.WriteEntry(&Entry{Time: time.Now(), Message: big})
+	s.WriteEntry(&Entry{Time: time.Now(), Message: "small"})
+	records := c.waitRecords(t, 2)
+	if !strings.Contains(records[0], big) || !strings.Contains(records[1], "small") {
+		t.Fatalf("got records of %d and %d bytes", len(records[0]), len(records[1]))
+	}
+}
+
+func TestShipperResumesFromTheSpoolWithoutDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	// Nothing listens yet, so the first entries wait in the spool
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	s := newTestShipper(t, addr, dir)
+	s.cfg.DrainTimeout = 10 * time.Millisecond
+	for i := 0; i < 3; i++ {
+		s.WriteEntry(&Entry{Time: time.Now(), Message: fmt.Sprintf("before %d", i)})
+	}
+	s.Close()
+
+	c := &fakeCollector{last: make(map[string]uint64), changed: make(chan struct{}, 1)}
+	if c.ln, err = net.Listen("tcp", addr); err != nil {
+		t.Skipf("port %s was taken meanwhile: %v", addr, err)
+	}
+	defer c.ln.Close()
+	go func() {
+		for {
+			conn, err := c.ln.Accept()
+			if err != nil {
+				return
+			}
+			go c.serve(conn)
+		}
+	}()
+
+	s = newTestShipper(t, addr, dir)
+	s.WriteEntry(&Entry{Time: time.Now(), Message: "after"})
+	c.waitRecords(t, 4)
+	s.Close()
+
+	// A third sink on the same spool has nothing left to send
+	s = newTestShipper(t, addr, dir)
+	s.WriteEntry(&Entry{Time: time.Now(), Message: "last"})
+	records := c.waitRecords(t, 5)
+	s.Close()
+	want := []string{"before 0", "before 1", "before 2", "after", "last"}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %q", len(records), len(want), records)
+	}
+	for i, w := range want {
+		if !strings.Contains(records[i], w) {
+			t.Errorf("record %d is %q, want %q", i, records[i], w)
+		}
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.resent > 0 {
+		t.Errorf("%d acknowledged records were sent again", c.resent)
+	}
+}
+
+func TestSpoolRecoversATornRecord(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := openSpool(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp.append([]byte("one"))
+	sp.append([]byte("two"))
+	sp.file.WriteString("3 thr") // The process died in the middle of a write
+	sp.close()
+
+	sp, err = openSpool(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.close()
+	sp.append([]byte("three"))
+	data, _, err := sp.read(sp.cursorAfter(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1 one\n2 two\n3 three\n"; string(data) != want {
+		t.Fatalf("spool holds %q, want %q", data, want)
+	}
+}
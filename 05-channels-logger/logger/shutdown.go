@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LostEntriesError is returned by Shutdown when its context expires before
+// the consumer goroutine has written every buffered entry.
+type LostEntriesError struct {
+	Lost int64 // Entries accepted by the logger that were never written to every sink
+	Err  error // The error of the context
+}
+
+func (e *LostEntriesError) Error() string {
+	return fmt.Sprintf("logger: %d entries lost during shutdown: %v", e.Lost, e.Err)
+}
+
+func (e *LostEntriesError) Unwrap() error {
+	return e.Err
+}
+
+// Shutdown stops accepting entries, then waits until the consumer goroutine
+// and the sinks have written everything that was already buffered, and closes
+// the sinks. Producers blocked on a full channel return immediately,
+// discarding their entry.
+//
+// If ctx expires first, the consumer stops writing and Shutdown returns a
+// *LostEntriesError with the number of entries that were not written.
+func (l *Logger) Shutdown(ctx context.Context) error {
+	c := l.core
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		c.mtx.Lock() // Waits for the producers that are still sending
+		c.closed = true
+		close(c.ch) // The consumer's for-range loop ends once the buffer is empty
+		c.mtx.Unlock()
+	})
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+	}
+	c.abortOnce.Do(func() {
+		close(c.abort)
+	})
+	<-c.drained // The consumer skips the rest of the channel without blocking
+	lost := c.accepted.Load() - c.written.Load()
+	var queued int64 // Entries handed over that a sink skips, the one it is writing is not lost
+	for _, s := range c.sinks {
+		queued = max(queued, s.lost())
+	}
+	lost += queued
+	if lost == 0 {
+		return nil // The last entry was written while we were giving up
+	}
+	return &LostEntriesError{Lost: lost, Err: ctx.Err()}
+}
+
+// Close is Shutdown without a deadline: it waits for as long as it takes to
+// write every buffered entry.
+func (l *Logger) Close() error {
+	return l.Shutdown(context.Background())
+}
+
+// syncPoll is how often Sync checks whether the entries were written.
+const syncPoll = time.Millisecond
+
+// Sync waits until every entry sent before the call has been written by the
+// sinks, without closing the logger. Entries that a sink buffers, or that
+// WithDedup holds back, are not flushed. It returns early if the logger is
+// shut down, or with the error of ctx if it expires.
+func (l *Logger) Sync(ctx context.Context) error {
+	c := l.core
+	wait := func(caughtUp func() bool) error {
+		for !caughtUp() {
+			select {
+			case <-c.done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(syncPoll):
+			}
+		}
+		return nil
+	}
+	target := c.accepted.Load()
+	err := wait(func() bool {
+		// Entries evicted by DropOldest leave accepted, so target may never be
+		// reached if nothing else is sent
+		written := c.written.Load()
+		return written >= target || written == c.accepted.Load()
+	})
+	if err != nil {
+		return err
+	}
+	for _, s := range c.sinks {
+		target := s.queued.Load()
+		err := wait(func() bool {
+			return s.handled.Load() >= target
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
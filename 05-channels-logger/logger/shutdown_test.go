@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedSink writes an entry for every value received from gate, so a test
+// can hold it in the middle of a write.
+type gatedSink struct {
+	gate chan struct{}
+
+	mtx      sync.Mutex
+	messages []string
+}
+
+func newGatedSink(open int) *gatedSink {
+	s := &gatedSink{gate: make(chan struct{}, open)}
+	for i := 0; i < open; i++ {
+		s.gate <- struct{}{}
+	}
+	return s
+}
+
+func (s *gatedSink) WriteEntry(e *Entry) error {
+	<-s.gate
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.messages = append(s.messages, e.Message)
+	return nil
+}
+
+func (s *gatedSink) Close() error { return nil }
+
+func (s *gatedSink) written() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func TestShutdownCountsTheEntriesItLoses(t *testing.T) {
+	sink := newGatedSink(3) // The fourth write blocks until the gate is closed
+	l := New(WithSink("gated", sink, LevelTrace), WithBufferSize(10), WithSinkTimeout(time.Hour))
+	for i := 0; i < 20; i++ {
+		l.Info(fmt.Sprint("entry ", i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := l.Shutdown(ctx)
+	var lost *LostEntriesError
+	if !errors.As(err, &lost) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown returned %v, want a *LostEntriesError", err)
+	}
+	close(sink.gate) // The write in progress finishes, the rest is skipped
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close after Shutdown gave up returned %v", err)
+	}
+
+	written := sink.written()
+	if len(written) != 4 {
+		t.Fatalf("sink wrote %q, want the first 4 entries", written)
+	}
+	if want := int64(20 - len(written)); lost.Lost != want {
+		t.Errorf("Shutdown lost %d entries, want %d", lost.Lost, want)
+	}
+}
+
+func TestShutdownWritesEverythingBuffered(t *testing.T) {
+	sink := newGatedSink(100)
+	l := New(WithSink("gated", sink, LevelTrace), WithBufferSize(4))
+	for i := 0; i < 50; i++ {
+		l.Info(fmt.Sprint("entry ", i))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := l.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	l.Info("after shutdown") // Discarded, and must not panic
+
+	written := sink.written()
+	if len(written) != 50 {
+		t.Fatalf("sink wrote %d entries, want 50", len(written))
+	}
+	for i, msg := range written {
+		if want := fmt.Sprint("entry ", i); msg != want {
+			t.Fatalf("entry %d is %q, want %q", i, msg, want)
+		}
+	}
+	if err := l.Shutdown(ctx); err != nil {
+		t.Fatalf("second Shutdown returned %v", err)
+	}
+}
+
+func TestSyncWaitsForTheSinks(t *testing.T) {
+	sink := newGatedSink(0)
+	l := New(WithSink("gated", sink, LevelTrace), WithSinkTimeout(time.Hour))
+	for i := 0; i < 5; i++ {
+		l.Info(fmt.Sprint("entry ", i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Sync(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Sync with a blocked sink returned %v, want the error of the context", err)
+	}
+	for i := 0; i < 5; i++ {
+		sink.gate <- struct{}{}
+	}
+	if err := l.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync returned %v", err)
+	}
+	if n := len(sink.written()); n != 5 {
+		t.Fatalf("sink wrote %d entries when Sync returned, want 5", n)
+	}
+
+	close(sink.gate)
+	l.Close()
+	if err := l.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync after Close returned %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+//go:build !windows
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// HandleSignals lets operators control the logger without a redeploy:
+//
+//   - SIGHUP reopens the files of the sinks, see Reopen, after logrotate
+//     moved them away. Sinks created with RotateConfig.ReopenOnSIGHUP
+//     reopen their file themselves and are skipped.
+//   - SIGUSR1 switches the global minimum level to LevelDebug, and back to
+//     the previous level on the next SIGUSR1.
+//   - SIGUSR2 logs the counters of Stats: queue depth, drops and the errors
+//     of each sink.
+//
+// Each signal is acknowledged with an entry at LevelWarning, so it shows up
+// whatever the level. The handling stops when the logger is closed or stop
+// is called.
+//
+//	kill -USR1 $(pidof app)
+func (l *Logger) HandleSignals() (stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	stopCh := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		defer signal.Stop(sigCh)
+		prev := LevelInfo // Restored by the SIGUSR1 that ends debugging
+		for {
+			select {
+			case sig := <-sigCh:
+				switch sig {
+				case syscall.SIGHUP:
+					if err := l.reopen(true); err != nil {
+						l.Error("Could not reopen the log files", String("signal", "SIGHUP"), Err(err))
+					} else {
+						l.Warning("Reopened the log files", String("signal", "SIGHUP"))
+					}
+				case syscall.SIGUSR1:
+					level := LevelDebug
+					if cur := l.Level(); cur > LevelDebug {
+						prev = cur
+					} else {
+						level = prev
+					}
+					l.SetLevel(level)
+					l.Warning("Changed the log level", String("signal", "SIGUSR1"), String("level", level.String()))
+				case syscall.SIGUSR2:
+					l.Warning("Logger stats", append([]Field{String("signal", "SIGUSR2")}, l.Stats().fields()...)...)
+				}
+			case <-stopCh:
+				return
+			case <-l.core.done:
+				return
+			}
+		}
+	}()
+	return sync.OnceFunc(func() {
+		close(stopCh)
+		<-stopped
+	})
+}
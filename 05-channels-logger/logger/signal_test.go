@@ -0,0 +1,56 @@
+//go:build !windows
+
+package logger
+
+import (
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// reopenSink counts its reopens. With self set it reopens itself on SIGHUP,
+// like a RotatingFileSink with ReopenOnSIGHUP.
+type reopenSink struct {
+	self    bool
+	reopens atomic.Int64
+}
+
+func (s *reopenSink) WriteEntry(e *Entry) error { return nil }
+func (s *reopenSink) Close() error              { return nil }
+func (s *reopenSink) handlesSIGHUP() bool       { return s.self }
+
+func (s *reopenSink) Reopen() error {
+	s.reopens.Add(1)
+	return nil
+}
+
+func TestHandleSignalsReopensEachFileOnce(t *testing.T) {
+	self, other := &reopenSink{self: true}, &reopenSink{}
+	l := New(WithSink("self", self, LevelInfo), WithSink("other", other, LevelInfo))
+	defer l.Close()
+	stop := l.HandleSignals()
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for other.reopens.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("SIGHUP did not reopen the sink")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// The sinks are reopened in order, so self was skipped by now
+	if n := self.reopens.Load(); n != 0 {
+		t.Errorf("HandleSignals reopened a sink that handles SIGHUP itself %d times", n)
+	}
+
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if self.reopens.Load() != 1 || other.reopens.Load() != 2 {
+		t.Errorf("Reopen did not reopen every sink: %d and %d reopens", self.reopens.Load(), other.reopens.Load())
+	}
+}
@@ -0,0 +1,465 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sink is a destination for entries. Each sink is driven by its own
+// goroutine, so WriteEntry is never called concurrently for the same sink,
+// and a slow or failing sink does not hold up the others.
+type Sink interface {
+	WriteEntry(e *Entry) error
+	Close() error
+}
+
+// Flusher is implemented by sinks that hold entries in a buffer. The sink
+// goroutine calls Flush once the oldest buffered entry has waited for
+// MaxLatency, and Close is expected to flush as well.
+type Flusher interface {
+	Flush() error
+	MaxLatency() time.Duration
+}
+
+// Reopener is implemented by sinks that write to a named file, so they can
+// switch to a new file at the same path once logrotate or a similar tool has
+// moved the old one. Logger.Reopen calls Reopen on the sink goroutine, so it
+// never runs concurrently with WriteEntry.
+type Reopener interface {
+	Reopen() error
+}
+
+// flushObserver is implemented by Flushers that also flush on their own, e.g.
+// when the batch is full, so every flush is timed and not only the ones of
+// the sink goroutine.
+type flushObserver interface {
+	setFlushObserver(observe func(time.Duration))
+}
+
+// sighupHandler is implemented by sinks that can reopen their file on SIGHUP
+// without HandleSignals, such as a RotatingFileSink with ReopenOnSIGHUP.
+type sighupHandler interface {
+	handlesSIGHUP() bool
+}
+
+// WriterSink encodes entries and writes them to an io.Writer.
+type WriterSink struct {
+	w       io.Writer
+	enc     Encoder
+	closer  io.Closer // Set when the sink owns w
+	path    string    // Set by NewFileSink, for Reopen
+	buf     []byte    // Holds the batch
+	batch   Batch
+	batched int // Entries in buf
+
+	observeFlush func(time.Duration) // Set by the sink goroutine, for the flush histogram
+}
+
+// Batch configures a WriterSink to write several entries with one call to
+// Write. The batch is written when it reaches MaxEntries or MaxBytes, when
+// its oldest entry has waited for MaxLatency, or when the sink is closed.
+type Batch struct {
+	MaxEntries int
+	MaxBytes   int // Defaults to 64 KiB
+	MaxLatency time.Duration
+}
+
+// NewWriterSink creates a sink that writes entries encoded with enc to w.
+// Closing the sink does not close w.
+func NewWriterSink(w io.Writer, enc Encoder) *WriterSink {
+	return &WriterSink{w: w, enc: enc}
+}
+
+// NewFileSink creates a sink that appends entries encoded with enc to the
+// file at path, creating it if needed. Closing the sink closes the file.
+func NewFileSink(path string, enc Encoder) (*WriterSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterSink{w: f, enc: enc, closer: f, path: path}, nil
+}
+
+// Batched makes the sink buffer entries according to b, and returns it.
+func (s *WriterSink) Batched(b Batch) *WriterSink {
+	if b.MaxBytes <= 0 {
+		b.MaxBytes = 64 << 10
+	}
+	s.batch = b
+	s.buf = make([]byte, 0, b.MaxBytes)
+	return s
+}
+
+func (s *WriterSink) WriteEntry(e *Entry) error {
+	if s.batch == (Batch{}) {
+		buf := getBuffer()
+		defer putBuffer(buf)
+		*buf = s.enc.Encode(*buf, e)
+		_, err := s.w.Write(*buf)
+		return err
+	}
+	s.buf = s.enc.Encode(s.buf, e)
+	s.batched++
+	if len(s.buf) >= s.batch.MaxBytes || (s.batch.MaxEntries > 0 && s.batched >= s.batch.MaxEntries) {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush writes the buffered batch, if any.
+func (s *WriterSink) Flush() error {
+	if s.batched == 0 {
+		return nil // Nothing buffered, or no batching
+	}
+	start := time.Now()
+	_, err := s.w.Write(s.buf)
+	s.buf = s.buf[:0] // A failed batch is not retried, like a failed entry
+	s.batched = 0
+	if s.observeFlush != nil {
+		s.observeFlush(time.Since(start))
+	}
+	return err
+}
+
+func (s *WriterSink) setFlushObserver(observe func(time.Duration)) {
+	s.observeFlush = observe
+}
+
+func (s *WriterSink) MaxLatency() time.Duration {
+	return s.batch.MaxLatency
+}
+
+// Reopen writes the pending batch and reopens the file of a sink created
+// with NewFileSink. It does nothing for other writers.
+func (s *WriterSink) Reopen() error {
+	if s.path == "" {
+		return nil
+	}
+	err := s.Flush()
+	f, oerr := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if oerr != nil {
+		return oerr // Keeps writing to the old file rather than nowhere
+	}
+	if cerr := s.closer.Close(); err == nil {
+		err = cerr
+	}
+	s.w, s.closer = f, f
+	return err
+}
+
+func (s *WriterSink) Close() error {
+	err := s.Flush()
+	if s.closer != nil {
+		if cerr := s.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// RingSink keeps the most recent entries in memory. It also serves them over
+// HTTP, see ServeHTTP.
+type RingSink struct {
+	mtx     sync.Mutex
+	entries []*Entry
+	next    int // Index where the next entry goes
+	full    bool
+	subs    map[chan *Entry]struct{}
+	closed  bool
+}
+
+// NewRingSink creates a sink that keeps the last size entries.
+func NewRingSink(size int) *RingSink {
+	return &RingSink{entries: make([]*Entry, size), subs: make(map[chan *Entry]struct{})}
+}
+
+func (s *RingSink) WriteEntry(e *Entry) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- e:
+		default: // A subscriber that cannot keep up misses entries
+		}
+	}
+	if len(s.entries) == 0 {
+		return nil
+	}
+	s.entries[s.next] = e
+	s.next = (s.next + 1) % len(s.entries)
+	if s.next == 0 {
+		s.full = true
+	}
+	return nil
+}
+
+// Entries returns the entries in the ring, oldest first.
+func (s *RingSink) Entries() []*Entry {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.entriesLocked()
+}
+
+func (s *RingSink) entriesLocked() []*Entry {
+	if !s.full {
+		return append([]*Entry(nil), s.entries[:s.next]...)
+	}
+	out := make([]*Entry, 0, len(s.entries))
+	out = append(out, s.entries[s.next:]...)
+	return append(out, s.entries[:s.next]...)
+}
+
+// Subscribe returns the entries in the ring and a channel that receives every
+// entry written after them, until cancel is called or the sink is closed.
+// Entries that do not fit in the channel buffer are dropped rather than
+// holding up the sink.
+func (s *RingSink) Subscribe(buffer int) (entries []*Entry, ch <-chan *Entry, cancel func()) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	c := make(chan *Entry, buffer)
+	if s.closed {
+		close(c)
+	} else {
+		s.subs[c] = struct{}{}
+	}
+	cancel = func() {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if _, ok := s.subs[c]; ok {
+			delete(s.subs, c)
+			close(c)
+		}
+	}
+	return s.entriesLocked(), c, cancel
+}
+
+// Close ends the subscriptions. The entries stay available.
+func (s *RingSink) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.closed = true
+	for ch := range s.subs {
+		delete(s.subs, ch)
+		close(ch)
+	}
+	return nil
+}
+
+type sinkConfig struct {
+	name string
+	sink Sink
+	min  Level
+}
+
+// WithSink adds a destination for the entries at min or above. The name is
+// used to tell sinks apart in Stats. When no sink is added, entries go to a
+// single sink built from WithOutput and WithEncoder.
+func WithSink(name string, s Sink, min Level) Option {
+	return func(c *config) {
+		c.sinks = append(c.sinks, sinkConfig{name, s, min})
+	}
+}
+
+// WithSinkTimeout sets how long the consumer goroutine waits for a sink whose
+// queue is full before marking it as failing. Entries for a failing sink are
+// dropped instead of waited for, until it manages to write again.
+// Defaults to one second.
+func WithSinkTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.sinkTimeout = d
+	}
+}
+
+// sinkRunner is the goroutine and queue in front of one sink.
+type sinkRunner struct {
+	sinkConfig
+	ch      chan *Entry
+	reopen  chan chan error // Requests from Logger.Reopen, answered between writes
+	timeout time.Duration
+
+	failing atomic.Bool  // Set by a failed write or a full queue, cleared by a good write
+	pending atomic.Int64 // Entries in the queue
+	skipped atomic.Int64 // Entries not written because Shutdown gave up
+	queued  atomic.Int64 // Entries ever queued, for Sync
+	handled atomic.Int64 // Entries ever taken off the queue, for Sync
+	written atomic.Int64
+	errors  atomic.Int64
+	dropped atomic.Int64
+
+	writeLatency histogram
+	flushLatency histogram
+
+	mtx     sync.Mutex
+	lastErr error
+}
+
+func newSinkRunner(cfg sinkConfig, bufferSize int, timeout time.Duration) *sinkRunner {
+	return &sinkRunner{
+		sinkConfig: cfg,
+		ch:         make(chan *Entry, bufferSize),
+		reopen:     make(chan chan error),
+		timeout:    timeout,
+	}
+}
+
+func (s *sinkRunner) run(abort <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	flusher, _ := s.sink.(Flusher)
+	selfTimed := false
+	if o, ok := s.sink.(flushObserver); ok && flusher != nil {
+		o.setFlushObserver(s.flushLatency.observe)
+		selfTimed = true
+	}
+	flush := func() {
+		start := time.Now()
+		err := flusher.Flush()
+		if !selfTimed {
+			s.flushLatency.observe(time.Since(start))
+		}
+		if err != nil {
+			s.recordError(err)
+		}
+	}
+	var timer *time.Timer
+	var timerCh <-chan time.Time // Nil, so it blocks forever, while nothing is buffered
+	for {
+		select {
+		case e, ok := <-s.ch:
+			if !ok {
+				if timer != nil {
+					timer.Stop()
+				}
+				if flusher != nil {
+					flush() // Close flushes too, but this one is timed
+				}
+				if err := s.sink.Close(); err != nil {
+					s.recordError(err)
+				}
+				return
+			}
+			if !s.take(abort) {
+				s.handled.Add(1)
+				continue // Shutdown already reported this entry as lost
+			}
+			start := time.Now()
+			err := s.sink.WriteEntry(e)
+			s.writeLatency.observe(time.Since(start))
+			if err != nil {
+				s.recordError(err)
+			} else {
+				s.written.Add(1)
+				s.failing.Store(false)
+			}
+			s.handled.Add(1)
+			if flusher != nil && timerCh == nil && flusher.MaxLatency() > 0 {
+				if timer == nil {
+					timer = time.NewTimer(flusher.MaxLatency())
+				} else {
+					timer.Reset(flusher.MaxLatency())
+				}
+				timerCh = timer.C
+			}
+		case <-timerCh:
+			timerCh = nil
+			flush()
+		case reply := <-s.reopen:
+			reply <- s.sink.(Reopener).Reopen()
+		}
+	}
+}
+
+// take tells whether an entry just taken off the queue should be written.
+// Once Shutdown gives up, they are skipped. The mutex keeps Shutdown from
+// counting an entry between the check and the update.
+func (s *sinkRunner) take(abort <-chan struct{}) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.pending.Add(-1)
+	select {
+	case <-abort:
+		s.skipped.Add(1)
+		return false
+	default:
+		return true
+	}
+}
+
+// lost returns the entries the sink will never write once Shutdown gave up:
+// those it skipped or will skip, not the one it may be writing.
+func (s *sinkRunner) lost() int64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.pending.Load() + s.skipped.Load()
+}
+
+func (s *sinkRunner) recordError(err error) {
+	s.errors.Add(1)
+	s.failing.Store(true)
+	s.mtx.Lock()
+	s.lastErr = err
+	s.mtx.Unlock()
+}
+
+// offer queues e for the sink. Healthy sinks are waited for, so a slow sink
+// slows the logger down instead of losing entries, but a failing sink only
+// gets the entries that fit in its queue.
+func (s *sinkRunner) offer(e *Entry, abort <-chan struct{}) {
+	select {
+	case s.ch <- e:
+		s.pending.Add(1)
+		s.queued.Add(1)
+		return
+	default:
+	}
+	if !s.failing.Load() {
+		timer := time.NewTimer(s.timeout)
+		defer timer.Stop()
+		select {
+		case s.ch <- e:
+			s.pending.Add(1)
+			s.queued.Add(1)
+			return
+		case <-timer.C:
+			s.failing.Store(true)
+		case <-abort:
+			s.skipped.Add(1) // Shutdown counts it as lost
+		}
+	}
+	s.dropped.Add(1)
+}
+
+// Reopen asks every sink that implements Reopener to reopen its file, and
+// waits until they have. Call it after an external tool rotated the files,
+// or let HandleSignals call it on SIGHUP.
+func (l *Logger) Reopen() error {
+	return l.reopen(false)
+}
+
+// reopen is Reopen, skipping the sinks that reopen themselves on SIGHUP when
+// onSIGHUP is set, so a signal reopens each file once.
+func (l *Logger) reopen(onSIGHUP bool) error {
+	var errs []error
+	for _, s := range l.core.sinks {
+		if _, ok := s.sink.(Reopener); !ok {
+			continue
+		}
+		if h, ok := s.sink.(sighupHandler); ok && onSIGHUP && h.handlesSIGHUP() {
+			continue
+		}
+		reply := make(chan error, 1)
+		select {
+		case s.reopen <- reply:
+		case <-l.core.done:
+			return os.ErrClosed
+		}
+		if err := <-reply; err != nil {
+			errs = append(errs, fmt.Errorf("logger: reopening sink %s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (h *histogram) count() int64 {
+	var n int64
+	for i := range h.counts {
+		n += h.counts[i].Load()
+	}
+	return n
+}
+
+func TestFlushLatencyObservesEveryFlush(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf, &TextEncoder{}).Batched(Batch{MaxEntries: 2, MaxLatency: time.Hour})
+	l := New(WithSink("batched", sink, LevelInfo))
+	for i := 0; i < 5; i++ {
+		l.Info("entry")
+	}
+	l.Close()
+
+	if n := strings.Count(buf.String(), "entry"); n != 5 {
+		t.Fatalf("sink wrote %d entries, want 5", n)
+	}
+	// Two batches filled up and the last entry was flushed by Close
+	if n := l.core.sinks[0].flushLatency.count(); n != 3 {
+		t.Errorf("observed %d flushes, want 3", n)
+	}
+}
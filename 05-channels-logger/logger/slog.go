@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SlogHandler is a slog.Handler that sends records through a Logger, so
+// libraries logging with log/slog end up in the same ordered stream as the
+// rest of the program.
+type SlogHandler struct {
+	l      *Logger
+	prefix string // Names of the open groups, each followed by a dot
+	attrs  []Field
+}
+
+// NewSlogHandler creates a handler that logs through l. Records are filtered
+// with the minimum level of l.
+func NewSlogHandler(l *Logger) *SlogHandler {
+	return &SlogHandler{l: l}
+}
+
+// Slog returns a *slog.Logger that logs through l.
+func (l *Logger) Slog() *slog.Logger {
+	return slog.New(NewSlogHandler(l))
+}
+
+// LevelFromSlog maps slog levels onto logger levels. Levels between two slog
+// constants round down, anything below slog.LevelDebug is LevelTrace, and
+// anything from slog.LevelError+4 up is LevelFatal.
+func LevelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return LevelTrace
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarning
+	case level < slog.LevelError+4:
+		return LevelError
+	}
+	return LevelFatal
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.l.Enabled(LevelFromSlog(level))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make([]Field, 0, len(h.attrs)+r.NumAttrs())
+	fields = h.l.core.appendContextFields(fields, ctx)
+	fields = append(fields, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now() // Every entry needs a time, even if the record has none
+	}
+	h.l.send(&Entry{Time: t, Level: LevelFromSlog(r.Level), Message: r.Message}, fields, r.PC)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make([]Field, 0, len(h.attrs)+len(attrs))
+	fields = append(fields, h.attrs...)
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.prefix, a)
+	}
+	return &SlogHandler{l: h.l, prefix: h.prefix, attrs: fields}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{l: h.l, prefix: h.prefix + name + ".", attrs: h.attrs}
+}
+
+// appendAttr flattens a, and the attributes of any group inside it, into
+// fields with dotted keys.
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields // Handlers must ignore empty attributes
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	case slog.KindString:
+		return append(fields, String(prefix+a.Key, a.Value.String()))
+	case slog.KindInt64:
+		return append(fields, Int64(prefix+a.Key, a.Value.Int64()))
+	case slog.KindUint64:
+		return append(fields, Uint64(prefix+a.Key, a.Value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, Float64(prefix+a.Key, a.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, Bool(prefix+a.Key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, Duration(prefix+a.Key, a.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, Time(prefix+a.Key, a.Value.Time()))
+	}
+	return append(fields, Any(prefix+a.Key, a.Value.Any()))
+}
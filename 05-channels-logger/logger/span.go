@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// SpanPhase tells whether an entry starts or ends a span.
+type SpanPhase uint8
+
+const (
+	SpanNone  SpanPhase = iota // An ordinary entry
+	SpanBegin                  // Sent by StartSpan
+	SpanEnd                    // Sent by Span.End
+)
+
+func (p SpanPhase) String() string {
+	switch p {
+	case SpanBegin:
+		return "begin"
+	case SpanEnd:
+		return "end"
+	}
+	return ""
+}
+
+// SpanEvent is set on the entries sent by StartSpan and Span.End.
+type SpanEvent struct {
+	Phase     SpanPhase
+	ID        uint64 // The same for the begin and end events of a span
+	Goroutine uint64 // Goroutine that started the span
+}
+
+// spanLevel is the level of span events. Sinks that should not see them can
+// have a higher minimum level.
+const spanLevel = LevelTrace
+
+// Span is an operation started with StartSpan. It is sent through the
+// logger channel like any other entry, so its begin and end events are
+// ordered with the entries logged around them.
+type Span struct {
+	l         *Logger
+	name      string
+	id        uint64
+	goroutine uint64
+	start     time.Time
+	ended     atomic.Bool
+}
+
+// spanIDs numbers the spans of the process.
+var spanIDs atomic.Uint64
+
+// StartSpan sends the begin event of a span named name, at LevelTrace, and
+// returns the span to End. The events carry the ID of the calling goroutine,
+// which takes a runtime.Stack call to find, so spans cost more than entries.
+// When LevelTrace is disabled, StartSpan returns nil, and End on a nil span
+// does nothing.
+//
+//	span := log.StartSpan("fetch", logger.String("url", url))
+//	defer span.End()
+func (l *Logger) StartSpan(name string, fields ...Field) *Span {
+	if !l.core.levels.enabled(spanLevel, l.component) {
+		return nil
+	}
+	s := &Span{l: l, name: name, id: spanIDs.Add(1), goroutine: goroutineID(), start: time.Now()}
+	s.send(SpanBegin, s.start, fields)
+	return s
+}
+
+// End sends the end event of the span, with its duration and fields. Only
+// the first call does anything, and it may come from any goroutine.
+func (s *Span) End(fields ...Field) {
+	if s == nil || s.ended.Swap(true) {
+		return
+	}
+	now := time.Now()
+	s.send(SpanEnd, now, append(fields, Duration("duration", now.Sub(s.start))))
+}
+
+// send is called directly by StartSpan and End, so the caller is found at
+// the same depth as for the logging methods.
+func (s *Span) send(phase SpanPhase, t time.Time, fields []Field) {
+	s.l.send(&Entry{
+		Time:    t,
+		Level:   spanLevel,
+		Message: s.name,
+		Span:    SpanEvent{Phase: phase, ID: s.id, Goroutine: s.goroutine},
+	}, fields, 0)
+}
+
+// goroutineID parses the ID of the current goroutine out of the first line
+// of its stack, "goroutine 18 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,90 @@
+package logger
+
+import "strings"
+
+// Stats is a snapshot of the logger counters.
+type Stats struct {
+	Queued         int   // Entries waiting in the channel
+	Capacity       int   // Size of the channel buffer
+	Written        int64 // Entries handed to the sinks by the consumer goroutine
+	Dropped        int64 // Entries dropped by the backpressure policy or Shutdown
+	DroppedByLevel map[Level]int64
+	Suppressed     int64 // Duplicates collapsed by WithDedup
+	Sinks          []SinkStats
+}
+
+// SinkStats holds the counters of one sink.
+type SinkStats struct {
+	Name      string
+	Queued    int64 // Entries waiting to be written
+	Written   int64
+	Dropped   int64 // Entries dropped because the sink was failing or too slow
+	Errors    int64 // Failed writes
+	Failing   bool
+	LastError error
+}
+
+// Stats returns the current counters of the logger. They are shared by all
+// the loggers created with With.
+func (l *Logger) Stats() Stats {
+	c := l.core
+	s := Stats{
+		Queued:         len(c.ch),
+		Capacity:       cap(c.ch),
+		Written:        c.written.Load(),
+		DroppedByLevel: make(map[Level]int64),
+	}
+	for i := range c.dropped {
+		if n := c.dropped[i].Load(); n > 0 {
+			s.Dropped += n
+			s.DroppedByLevel[levelAt(i)] = n
+		}
+	}
+	if c.dedup != nil {
+		s.Suppressed = c.dedup.suppressed.Load()
+	}
+	for _, r := range c.sinks {
+		r.mtx.Lock()
+		lastErr := r.lastErr
+		r.mtx.Unlock()
+		s.Sinks = append(s.Sinks, SinkStats{
+			Name:      r.name,
+			Queued:    r.pending.Load(),
+			Written:   r.written.Load(),
+			Dropped:   r.dropped.Load(),
+			Errors:    r.errors.Load(),
+			Failing:   r.failing.Load(),
+			LastError: lastErr,
+		})
+	}
+	return s
+}
+
+// fields returns the counters as fields, the ones of each sink prefixed with
+// its name, like "stdout.errors".
+func (s Stats) fields() []Field {
+	fields := []Field{
+		Int("queued", s.Queued),
+		Int("capacity", s.Capacity),
+		Int64("written", s.Written),
+		Int64("dropped", s.Dropped),
+	}
+	for l := LevelTrace; l <= LevelFatal; l++ {
+		if n := s.DroppedByLevel[l]; n > 0 {
+			fields = append(fields, Int64("dropped."+strings.ToLower(l.String()), n))
+		}
+	}
+	fields = append(fields, Int64("suppressed", s.Suppressed))
+	for _, sink := range s.Sinks {
+		fields = append(fields,
+			Int64(sink.Name+".queued", sink.Queued),
+			Int64(sink.Name+".written", sink.Written),
+			Int64(sink.Name+".dropped", sink.Dropped),
+			Int64(sink.Name+".errors", sink.Errors),
+			Bool(sink.Name+".failing", sink.Failing))
+		if sink.LastError != nil {
+			fields = append(fields, String(sink.Name+".last_error", sink.LastError.Error()))
+		}
+	}
+	return fields
+}
@@ -0,0 +1,280 @@
+package logger
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Facility is the syslog facility, the part of the priority that tells the
+// collector which kind of program sent the message.
+type Facility int
+
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+	FacilityLocal0 Facility = iota + 4
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// SyslogSeverity maps a level onto the severities defined by RFC 5424.
+func SyslogSeverity(l Level) int {
+	switch {
+	case l >= LevelFatal:
+		return 2 // Critical
+	case l >= LevelError:
+		return 3 // Error
+	case l >= LevelWarning:
+		return 4 // Warning
+	case l >= LevelInfo:
+		return 6 // Informational
+	}
+	return 7 // Debug
+}
+
+// SDElement is an RFC 5424 structured-data element, e.g.
+// [origin@32473 region="eu-west-1"].
+type SDElement struct {
+	ID     string
+	Params []SDParam
+}
+
+type SDParam struct {
+	Name  string
+	Value string
+}
+
+// SyslogEncoder writes entries as RFC 5424 messages:
+//
+//	<14>1 2006-01-02T15:04:05.000000Z host app 1234 - [origin@32473 region="eu"] message
+//
+// Unlike the line-based encoders it writes no trailing newline, framing is up
+// to the transport.
+type SyslogEncoder struct {
+	Facility       Facility
+	Hostname       string      // Set to os.Hostname() by NewSyslogEncoder
+	AppName        string      // Set to the name of the executable by NewSyslogEncoder
+	ProcID         string      // Set to the process ID by NewSyslogEncoder
+	MsgID          string      // Defaults to the component of the entry
+	StructuredData []SDElement // Added to every message
+
+	// FieldsSDID, when set, is the ID of an element holding the fields of
+	// each entry, e.g. "fields@32473". Otherwise they follow the message as
+	// key=value pairs.
+	FieldsSDID string
+}
+
+// NewSyslogEncoder creates an encoder with the hostname, app-name and
+// process ID of the running process. Empty header fields are written as "-".
+func NewSyslogEncoder(facility Facility) *SyslogEncoder {
+	enc := &SyslogEncoder{Facility: facility, ProcID: strconv.Itoa(os.Getpid())}
+	enc.Hostname, _ = os.Hostname()
+	if exe, err := os.Executable(); err == nil {
+		enc.AppName = filepath.Base(exe)
+	}
+	return enc
+}
+
+func (enc *SyslogEncoder) Encode(dst []byte, e *Entry) []byte {
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(enc.Facility)*8+int64(SyslogSeverity(e.Level)), 10)
+	dst = append(dst, ">1 "...)
+	dst = e.Time.AppendFormat(dst, "2006-01-02T15:04:05.000000Z07:00")
+	dst = append(dst, ' ')
+	dst = appendHeaderField(dst, enc.Hostname, 255)
+	dst = append(dst, ' ')
+	dst = appendHeaderField(dst, enc.AppName, 48)
+	dst = append(dst, ' ')
+	dst = appendHeaderField(dst, enc.ProcID, 128)
+	dst = append(dst, ' ')
+	msgID := enc.MsgID
+	if msgID == "" {
+		msgID = e.Component
+	}
+	dst = appendHeaderField(dst, msgID, 32)
+	dst = append(dst, ' ')
+
+	hasSD := false
+	for _, el := range enc.StructuredData {
+		dst = append(dst, '[')
+		dst = appendSDName(dst, el.ID)
+		for _, p := range el.Params {
+			dst = appendSDParam(dst, p.Name, p.Value)
+		}
+		dst = append(dst, ']')
+		hasSD = true
+	}
+	fields := e.allFields()
+	if enc.FieldsSDID != "" && len(fields) > 0 {
+		dst = append(dst, '[')
+		dst = appendSDName(dst, enc.FieldsSDID)
+		for _, f := range fields {
+			dst = appendSDParam(dst, f.Key, f.text())
+		}
+		dst = append(dst, ']')
+		hasSD = true
+	}
+	if !hasSD {
+		dst = append(dst, '-')
+	}
+
+	dst = append(dst, ' ')
+	dst = appendEscaped(dst, e.Message) // Keeps the message on one line for collectors that split on newlines
+	if enc.FieldsSDID == "" {
+		for _, f := range fields {
+			dst = append(dst, ' ')
+			dst = appendLogfmtField(dst, f)
+		}
+	}
+	return dst
+}
+
+// appendHeaderField writes a header field as printable ASCII, or "-" when it
+// is empty, as required by RFC 5424.
+func appendHeaderField(dst []byte, s string, maxLen int) []byte {
+	if s == "" {
+		return append(dst, '-')
+	}
+	for i := 0; i < len(s) && i < maxLen; i++ {
+		if c := s[i]; c > ' ' && c < 0x7f {
+			dst = append(dst, c)
+		} else {
+			dst = append(dst, '_')
+		}
+	}
+	return dst
+}
+
+// appendSDParam writes a space and name="value", escaping the characters
+// RFC 5424 requires in values.
+func appendSDParam(dst []byte, name, value string) []byte {
+	dst = append(dst, ' ')
+	dst = appendSDName(dst, name)
+	dst = append(dst, `="`...)
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c == '"' || c == '\\' || c == ']' {
+			dst = append(dst, '\\')
+		}
+		dst = append(dst, value[i])
+	}
+	return append(dst, '"')
+}
+
+// appendSDName replaces the characters that are not allowed in names.
+func appendSDName(dst []byte, s string) []byte {
+	if s == "" {
+		return append(dst, '_')
+	}
+	for i := 0; i < len(s) && i < 32; i++ {
+		if c := s[i]; c > ' ' && c < 0x7f && c != '=' && c != ']' && c != '"' {
+			dst = append(dst, c)
+		} else {
+			dst = append(dst, '_')
+		}
+	}
+	return dst
+}
+
+// SyslogSink sends entries to a syslog collector over a Unix datagram socket,
+// UDP or TCP. Over TCP, messages are framed with octet counting (RFC 6587).
+type SyslogSink struct {
+	network string
+	addr    string
+	enc     *SyslogEncoder
+	conn    net.Conn
+}
+
+// syslogWriteTimeout stops a stuck collector from blocking the sink forever.
+const syslogWriteTimeout = 5 * time.Second
+
+// NewSyslogSink connects to a syslog collector. network is "unixgram", "udp"
+// or "tcp", and an empty addr for "unixgram" means the local /dev/log.
+func NewSyslogSink(network, addr string, enc *SyslogEncoder) (*SyslogSink, error) {
+	switch network {
+	case "unixgram", "udp", "tcp":
+	default:
+		return nil, errors.New("logger: unsupported syslog network " + strconv.Quote(network))
+	}
+	if network == "unixgram" && addr == "" {
+		addr = "/dev/log"
+	}
+	s := &SyslogSink{network: network, addr: addr, enc: enc}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) connect() error {
+	conn, err := net.DialTimeout(s.network, s.addr, syslogWriteTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *SyslogSink) WriteEntry(e *Entry) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	*buf = s.enc.Encode(*buf, e)
+	msg := *buf
+	if s.network == "tcp" {
+		frame := getBuffer()
+		defer putBuffer(frame)
+		*frame = strconv.AppendInt(*frame, int64(len(*buf)), 10)
+		*frame = append(*frame, ' ')
+		*frame = append(*frame, *buf...)
+		msg = *frame
+	}
+	err := s.write(msg)
+	if err != nil {
+		// The collector may have closed an idle connection, or restarted and
+		// left a datagram socket with nobody at the other end, so try a new
+		// one once, like log/syslog. A partially written frame is lost either
+		// way.
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+		err = s.write(msg)
+	}
+	return err
+}
+
+func (s *SyslogSink) write(msg []byte) error {
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
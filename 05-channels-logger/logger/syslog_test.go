@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSyslogEncoder() *SyslogEncoder {
+	return &SyslogEncoder{Facility: FacilityLocal0, Hostname: "host", AppName: "app", ProcID: "1"}
+}
+
+var syslogTestTime = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func TestSyslogSinkFramesMessagesOverTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	frames := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var got []string
+		for {
+			n, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			size, err := strconv.Atoi(strings.TrimSuffix(n, " "))
+			if err != nil {
+				break
+			}
+			msg := make([]byte, size)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				break
+			}
+			got = append(got, string(msg))
+		}
+		frames <- got
+	}()
+
+	s, err := NewSyslogSink("tcp", ln.Addr().String(), newTestSyslogEncoder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []string{"first", "second\nline", "third"}
+	for _, m := range messages {
+		if err := s.WriteEntry(&Entry{Time: syslogTestTime, Level: LevelWarning, Message: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	got := <-frames
+	want := []string{
+		`<132>1 2024-05-01T10:00:00.000000Z host app 1 - - first`,
+		`<132>1 2024-05-01T10:00:00.000000Z host app 1 - - second\nline`,
+		`<132>1 2024-05-01T10:00:00.000000Z host app 1 - - third`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("collector got %d frames, want %d: %q", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("frame %d is %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSyslogSinkSendsOneDatagramPerMessageOverUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	s, err := NewSyslogSink("udp", pc.LocalAddr().String(), newTestSyslogEncoder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	e := &Entry{Time: syslogTestTime, Level: LevelError, Message: "failed", Fields: []Field{Int("attempt", 3)}}
+	if err := s.WriteEntry(e); err != nil {
+		t.Fatal(err)
+	}
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf[:n]), `<131>1 2024-05-01T10:00:00.000000Z host app 1 - - failed attempt=3`; got != want {
+		t.Fatalf("datagram is %q, want %q", got, want)
+	}
+}
+
+func TestSyslogSinkRedialsARestartedDatagramCollector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skipf("unixgram sockets are not available: %v", err)
+	}
+	s, err := NewSyslogSink("unixgram", path, newTestSyslogEncoder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.WriteEntry(&Entry{Time: syslogTestTime, Message: "before"}); err != nil {
+		t.Fatal(err)
+	}
+	pc.Close()
+	os.Remove(path)
+
+	if pc, err = net.ListenPacket("unixgram", path); err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if err := s.WriteEntry(&Entry{Time: syslogTestTime, Message: "after"}); err != nil {
+		t.Fatalf("writing after the collector restarted: %v", err)
+	}
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); !strings.HasSuffix(got, " after") {
+		t.Fatalf("restarted collector got %q", got)
+	}
+}
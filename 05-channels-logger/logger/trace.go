@@ -0,0 +1,155 @@
+package logger
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// traceFlushLatency is how long events wait in the buffer of a TraceSink.
+const traceFlushLatency = 100 * time.Millisecond
+
+// TraceSink writes spans in the Chrome Trace Event format, which Perfetto
+// (https://ui.perfetto.dev) and chrome://tracing can load. Each goroutine
+// that starts spans gets its own track, so it shows how they overlap in
+// time. Ordinary entries become instant events on a track of their own.
+// Give the sink a minimum level of LevelTrace to get the spans:
+//
+//	trace, err := logger.NewTraceFileSink("trace.json")
+//	log := logger.New(logger.WithLevel(logger.LevelTrace),
+//		logger.WithSink("stdout", logger.NewWriterSink(os.Stdout, &logger.TextEncoder{}), logger.LevelInfo),
+//		logger.WithSink("trace", trace, logger.LevelTrace))
+type TraceSink struct {
+	w       *bufio.Writer
+	closer  io.Closer // Set when the sink owns the writer
+	pid     int
+	started bool                // The opening bracket was written
+	named   map[uint64]struct{} // Goroutines whose track has a name
+	buf     []byte
+}
+
+// NewTraceSink creates a sink that writes a trace to w. Closing the sink
+// does not close w.
+func NewTraceSink(w io.Writer) *TraceSink {
+	return &TraceSink{w: bufio.NewWriter(w), pid: os.Getpid(), named: make(map[uint64]struct{})}
+}
+
+// NewTraceFileSink creates a sink that writes a trace to a new file at path.
+// Closing the sink closes the file.
+func NewTraceFileSink(path string) (*TraceSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s := NewTraceSink(f)
+	s.closer = f
+	return s, nil
+}
+
+func (s *TraceSink) WriteEntry(e *Entry) error {
+	if !s.started {
+		s.started = true
+		s.buf = append(s.buf[:0], "[\n"...)
+		s.buf = s.appendMetadata(s.buf, 0, "process_name", filepath.Base(os.Args[0]))
+		s.buf = append(s.buf, ",\n"...)
+		s.buf = s.appendMetadata(s.buf, 0, "thread_name", "log entries")
+	} else {
+		s.buf = s.buf[:0]
+	}
+	tid := e.Span.Goroutine
+	if _, ok := s.named[tid]; !ok && e.Span.Phase != SpanNone {
+		s.named[tid] = struct{}{}
+		s.buf = append(s.buf, ",\n"...)
+		s.buf = s.appendMetadata(s.buf, tid, "thread_name", "goroutine "+strconv.FormatUint(tid, 10))
+	}
+
+	s.buf = append(s.buf, ",\n{\"name\":"...)
+	s.buf = appendJSONString(s.buf, e.Message)
+	if e.Component != "" {
+		s.buf = append(s.buf, `,"cat":`...)
+		s.buf = appendJSONString(s.buf, e.Component)
+	}
+	switch e.Span.Phase {
+	case SpanBegin:
+		s.buf = append(s.buf, `,"ph":"B"`...)
+	case SpanEnd:
+		s.buf = append(s.buf, `,"ph":"E"`...)
+	default:
+		s.buf = append(s.buf, `,"ph":"i","s":"t"`...)
+	}
+	s.buf = append(s.buf, `,"ts":`...)
+	s.buf = appendMicros(s.buf, e.Time)
+	s.buf = s.appendIDs(s.buf, tid)
+	s.buf = append(s.buf, `,"args":{`...)
+	if e.Span.Phase == SpanNone {
+		s.buf = append(s.buf, `"level":"`...)
+		s.buf = append(s.buf, e.Level.String()...)
+		s.buf = append(s.buf, '"')
+	}
+	for i, f := range e.Fields {
+		if i > 0 || e.Span.Phase == SpanNone {
+			s.buf = append(s.buf, ',')
+		}
+		s.buf = appendJSONString(s.buf, f.Key)
+		s.buf = append(s.buf, ':')
+		s.buf = appendJSONField(s.buf, f)
+	}
+	s.buf = append(s.buf, "}}"...)
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+// appendMetadata appends an event that names the process or a track.
+func (s *TraceSink) appendMetadata(dst []byte, tid uint64, kind, name string) []byte {
+	dst = append(dst, `{"name":"`...)
+	dst = append(dst, kind...)
+	dst = append(dst, `","ph":"M"`...)
+	dst = s.appendIDs(dst, tid)
+	dst = append(dst, `,"args":{"name":`...)
+	dst = appendJSONString(dst, name)
+	return append(dst, "}}"...)
+}
+
+func (s *TraceSink) appendIDs(dst []byte, tid uint64) []byte {
+	dst = append(dst, `,"pid":`...)
+	dst = strconv.AppendInt(dst, int64(s.pid), 10)
+	dst = append(dst, `,"tid":`...)
+	return strconv.AppendUint(dst, tid, 10)
+}
+
+// appendMicros appends t in microseconds since the Unix epoch, the unit of
+// trace events, keeping the nanoseconds as decimals.
+func appendMicros(dst []byte, t time.Time) []byte {
+	ns := t.UnixNano()
+	dst = strconv.AppendInt(dst, ns/1000, 10)
+	frac := ns % 1000
+	return append(dst, '.', byte('0'+frac/100), byte('0'+frac/10%10), byte('0'+frac%10))
+}
+
+func (s *TraceSink) Flush() error {
+	return s.w.Flush()
+}
+
+func (s *TraceSink) MaxLatency() time.Duration {
+	return traceFlushLatency
+}
+
+// Close ends the JSON array and flushes it. A trace cut short by a crash
+// lacks the closing bracket, which chrome://tracing tolerates.
+func (s *TraceSink) Close() error {
+	if s.started {
+		s.w.WriteString("\n]\n")
+	} else {
+		s.w.WriteString("[]\n")
+	}
+	err := s.w.Flush()
+	if s.closer != nil {
+		if cerr := s.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -18,18 +18,20 @@ type logEntry struct {
 }
 
 var logCh = make(chan logEntry, 50)
+var loggerDoneCh = make(chan struct{})
 
 func logger() {
 	for entry := range logCh {
 		fmt.Printf("%v - [%v] %v\n", entry.time.Format("2006-01-02T15:04:05"), entry.severity, entry.message)
 	}
+	close(loggerDoneCh) // Only reached once logCh is closed and every entry has been printed
 }
 
 func loggerDemo() {
 	go logger()
 	defer func() {
-		time.Sleep(100 * time.Millisecond)
-		close(logCh) // Closes channel 100ms after the logging is finished
+		close(logCh)   // No more entries will be sent, the logger can finish
+		<-loggerDoneCh // Waits for the buffered entries instead of guessing how long they take
 	}()
 	logCh <- logEntry{time.Now(), logInfo, "App is starting"}
 	time.Sleep(2 * time.Second)
@@ -38,14 +40,24 @@ func loggerDemo() {
 
 var betterLogCh = make(chan logEntry, 50)
 var doneCh = make(chan struct{}) // Structs with no fields require no memory allocation
+var betterLoggerDoneCh = make(chan struct{})
 
 func betterLogger() {
+	defer close(betterLoggerDoneCh)
 	for {
 		select {
 		case entry := <-betterLogCh:
 			fmt.Printf("%v - [%v] %v\n", entry.time.Format("2006-01-02T15:04:05"), entry.severity, entry.message)
 		case <-doneCh:
-			break
+			// A bare `break` would only leave the select, not the for loop
+			for { // Drains the entries that were still buffered
+				select {
+				case entry := <-betterLogCh:
+					fmt.Printf("%v - [%v] %v\n", entry.time.Format("2006-01-02T15:04:05"), entry.severity, entry.message)
+				default:
+					return
+				}
+			}
 		}
 	}
 }
@@ -53,11 +65,11 @@ func betterLogger() {
 func betterLoggerDemo() {
 	fmt.Println("Better way to handle signals:")
 	go betterLogger()
-	logCh <- logEntry{time.Now(), logInfo, "App is starting"}
+	betterLogCh <- logEntry{time.Now(), logInfo, "App is starting"}
 	time.Sleep(2 * time.Second)
-	logCh <- logEntry{time.Now(), logInfo, "App is shutting down"}
-	time.Sleep(100 * time.Millisecond)
+	betterLogCh <- logEntry{time.Now(), logInfo, "App is shutting down"}
 	doneCh <- struct{}{} // Send an empty struct on the channel to indicate it can terminate
+	<-betterLoggerDoneCh // Waits until the logger has printed the remaining entries
 }
 
 func main() {